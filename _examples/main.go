@@ -14,11 +14,11 @@ type configJsonBody struct {
 	Id            string
 	CaptchaType   string
 	VerifyValue   string
-	DriverAudio   *base64Captcha.DriverAudio
-	DriverString  *base64Captcha.DriverString
-	DriverChinese *base64Captcha.DriverChinese
-	DriverMath    *base64Captcha.DriverMath
-	DriverDigit   *base64Captcha.DriverDigit
+	DriverAudio   json.RawMessage
+	DriverString  json.RawMessage
+	DriverChinese json.RawMessage
+	DriverMath    json.RawMessage
+	DriverDigit   json.RawMessage
 }
 
 var store = base64Captcha.DefaultMemStore
@@ -33,20 +33,19 @@ func generateCaptchaHandler(w http.ResponseWriter, r *http.Request) {
 		log.Println(err)
 	}
 	defer r.Body.Close()
-	var driver base64Captcha.Driver
 
-	//choose driver
-	switch param.CaptchaType {
-	case "audio":
-		driver = param.DriverAudio
-	case "string":
-		driver = param.DriverString.ConvertFonts()
-	case "math":
-		driver = param.DriverMath.ConvertFonts()
-	case "chinese":
-		driver = param.DriverChinese.ConvertFonts()
-	default:
-		driver = param.DriverDigit
+	//choose driver config, the registry builds the driver with its fonts loaded
+	config := map[string]json.RawMessage{
+		"audio":   param.DriverAudio,
+		"string":  param.DriverString,
+		"math":    param.DriverMath,
+		"chinese": param.DriverChinese,
+		"digit":   param.DriverDigit,
+	}[param.CaptchaType]
+	driver, err := base64Captcha.NewDriverByName(param.CaptchaType, config)
+	if err != nil {
+		log.Println(err)
+		driver = base64Captcha.DefaultDriverDigit
 	}
 	c := base64Captcha.NewCaptcha(driver, store)
 	id, b64s,_, err := c.Generate()
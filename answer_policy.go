@@ -0,0 +1,141 @@
+package base64Captcha
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// AnswerNormalizer rewrites an answer before it is compared. It is applied
+// to both the stored and the submitted answer.
+type AnswerNormalizer interface {
+	Normalize(answer string) string
+}
+
+// AnswerNormalizerFunc adapts a function to the AnswerNormalizer interface.
+type AnswerNormalizerFunc func(answer string) string
+
+// Normalize calls f(answer).
+func (f AnswerNormalizerFunc) Normalize(answer string) string {
+	return f(answer)
+}
+
+// Comparer reports whether a submitted answer matches the stored answer.
+// Both answers are normalized already.
+type Comparer interface {
+	Compare(stored, answer string) bool
+}
+
+// ComparerFunc adapts a function to the Comparer interface.
+type ComparerFunc func(stored, answer string) bool
+
+// Compare calls f(stored, answer).
+func (f ComparerFunc) Compare(stored, answer string) bool {
+	return f(stored, answer)
+}
+
+var (
+	// TrimNormalizer removes leading and trailing white space.
+	TrimNormalizer AnswerNormalizer = AnswerNormalizerFunc(strings.TrimSpace)
+	// FoldCaseNormalizer maps the answer to lower case.
+	FoldCaseNormalizer AnswerNormalizer = AnswerNormalizerFunc(strings.ToLower)
+	// NFKCNormalizer applies Unicode NFKC normalization, which maps
+	// full-width letters and digits to their ASCII forms.
+	NFKCNormalizer AnswerNormalizer = AnswerNormalizerFunc(norm.NFKC.String)
+	// ConfusableNormalizer folds characters that are easily mistaken for each
+	// other in a captcha image: O and o read as 0, I, i and l read as 1.
+	ConfusableNormalizer AnswerNormalizer = AnswerNormalizerFunc(foldConfusables)
+
+	// DefaultNormalizer trims the answer and folds its case.
+	DefaultNormalizer = ChainNormalizers(TrimNormalizer, FoldCaseNormalizer)
+	// CaseSensitiveNormalizer only trims the answer.
+	CaseSensitiveNormalizer = TrimNormalizer
+
+	// ConstantTimeComparer compares answers in constant time, so that the
+	// time taken does not tell how much of an answer was right.
+	ConstantTimeComparer Comparer = ComparerFunc(func(stored, answer string) bool {
+		return subtle.ConstantTimeCompare([]byte(stored), []byte(answer)) == 1
+	})
+)
+
+// ChainNormalizers returns a normalizer applying the given normalizers in order.
+func ChainNormalizers(normalizers ...AnswerNormalizer) AnswerNormalizer {
+	return AnswerNormalizerFunc(func(answer string) string {
+		for _, n := range normalizers {
+			answer = n.Normalize(answer)
+		}
+		return answer
+	})
+}
+
+// foldConfusables replaces look-alike characters with a canonical one.
+func foldConfusables(answer string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case 'O', 'o':
+			return '0'
+		case 'I', 'i', 'l':
+			return '1'
+		}
+		return r
+	}, answer)
+}
+
+// AnswerPolicy decides whether a submitted answer matches the stored one.
+// The zero value trims answers, folds their case and compares them in
+// constant time.
+type AnswerPolicy struct {
+	// Normalizer defaults to DefaultNormalizer.
+	Normalizer AnswerNormalizer
+	// Comparer defaults to ConstantTimeComparer.
+	Comparer Comparer
+}
+
+// AnswerPolicyStore is implemented by stores whose answer comparison can be
+// configured. SetAnswerPolicy must be called before the store is used.
+// Captcha overrides the policy of the store while its Normalizer or Comparer
+// is set.
+type AnswerPolicyStore interface {
+	SetAnswerPolicy(p AnswerPolicy)
+}
+
+// Normalize normalizes an answer.
+func (p AnswerPolicy) Normalize(answer string) string {
+	if p.Normalizer == nil {
+		return DefaultNormalizer.Normalize(answer)
+	}
+	return p.Normalizer.Normalize(answer)
+}
+
+// Match reports whether answer matches the stored answer. An answer which
+// is empty after normalization never matches.
+func (p AnswerPolicy) Match(stored, answer string) bool {
+	answer = p.Normalize(answer)
+	if answer == "" {
+		return false
+	}
+	stored = p.Normalize(stored)
+	if p.Comparer == nil {
+		return ConstantTimeComparer.Compare(stored, answer)
+	}
+	return p.Comparer.Compare(stored, answer)
+}
+
+// answerPolicyKey is the context key of the answer policy set by Captcha.
+type answerPolicyKey struct{}
+
+// withAnswerPolicy returns a context carrying the answer policy.
+func withAnswerPolicy(ctx context.Context, p AnswerPolicy) context.Context {
+	return context.WithValue(ctx, answerPolicyKey{}, p)
+}
+
+// answerPolicyFrom returns the answer policy carried by ctx, or def if the
+// context has none.
+func answerPolicyFrom(ctx context.Context, def AnswerPolicy) AnswerPolicy {
+	if p, ok := ctx.Value(answerPolicyKey{}).(AnswerPolicy); ok {
+		return p
+	}
+	return def
+}
@@ -0,0 +1,109 @@
+package base64Captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestAnswerPolicy_Match(t *testing.T) {
+	nfkc := ChainNormalizers(NFKCNormalizer, DefaultNormalizer)
+	confusable := ChainNormalizers(DefaultNormalizer, ConfusableNormalizer)
+	tests := []struct {
+		name           string
+		policy         AnswerPolicy
+		stored, answer string
+		want           bool
+	}{
+		{"default", AnswerPolicy{}, "abc", " ABC ", true},
+		{"default mismatch", AnswerPolicy{}, "abc", "abd", false},
+		{"default longer", AnswerPolicy{}, "abc", "abcd", false},
+		{"empty", AnswerPolicy{}, "", "", false},
+		{"blank", AnswerPolicy{}, "abc", "   ", false},
+		{"case sensitive", AnswerPolicy{Normalizer: CaseSensitiveNormalizer}, "aBc", " aBc", true},
+		{"case sensitive mismatch", AnswerPolicy{Normalizer: CaseSensitiveNormalizer}, "aBc", "abc", false},
+		{"full width", AnswerPolicy{Normalizer: nfkc}, "ab12", "ＡＢ１２", true},
+		{"full width default", AnswerPolicy{}, "ab12", "ＡＢ１２", false},
+		{"confusable", AnswerPolicy{Normalizer: confusable}, "o1l0", "0IIO", true},
+		{"confusable mismatch", AnswerPolicy{Normalizer: confusable}, "o1l0", "0II8", false},
+		{"comparer", AnswerPolicy{Comparer: ComparerFunc(func(stored, answer string) bool { return true })}, "abc", "x", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.policy.Match(tt.stored, tt.answer); got != tt.want {
+				t.Errorf("Match(%q, %q) = %v, want %v", tt.stored, tt.answer, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnswerPolicy_Stores(t *testing.T) {
+	policy := AnswerPolicy{Normalizer: CaseSensitiveNormalizer}
+	mem := NewMemoryStore(10, time.Hour)
+	syncMap := NewStoreSyncMap(time.Hour)
+	hash := NewHashStore(NewMemoryStore(10, time.Hour), []byte("secret"))
+	stores := map[string]StoreContext{
+		"memory":   mem.(StoreContext),
+		"sync map": syncMap,
+		"hash":     hash.(StoreContext),
+	}
+	for name, s := range stores {
+		t.Run(name, func(t *testing.T) {
+			s.(AnswerPolicyStore).SetAnswerPolicy(policy)
+			ctx := context.Background()
+			_ = s.SetContext(ctx, "id", "aBc")
+			if ok, _ := s.VerifyContext(ctx, "id", "abc", false); ok {
+				t.Error("case folded with a case sensitive policy")
+			}
+			if ok, _ := s.VerifyContext(ctx, "id", " aBc ", true); !ok {
+				t.Error("right answer failed")
+			}
+
+			// A policy passed by Captcha overrides the store policy.
+			ctx = withAnswerPolicy(ctx, AnswerPolicy{})
+			_ = s.SetContext(ctx, "id", "aBc")
+			if err := s.CheckContext(ctx, "id", "ABC", true); err != nil {
+				t.Errorf("CheckContext() = %v, want nil", err)
+			}
+		})
+	}
+}
+
+func TestCaptcha_AnswerPolicy(t *testing.T) {
+	for _, store := range []Store{NewMemoryStore(10, time.Hour), &legacyStore{m: map[string]string{}}, NewHashStore(NewMemoryStore(10, time.Hour), []byte("k"))} {
+		c := NewCaptcha(DefaultDriverDigit, store)
+		c.Normalizer = ChainNormalizers(NFKCNormalizer, DefaultNormalizer)
+		id, _, answer, err := c.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		full := []rune(answer)
+		for i, r := range full {
+			full[i] = r - '0' + '０'
+		}
+		if !c.Verify(id, string(full), true) {
+			t.Errorf("%T: full width answer %q failed", store, string(full))
+		}
+	}
+}
+
+func TestStatelessCaptcha_Normalizer(t *testing.T) {
+	c := NewStatelessCaptcha(DefaultDriverDigit, []byte("secret"), time.Minute)
+	c.Normalizer = ChainNormalizers(DefaultNormalizer, ConfusableNormalizer)
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []byte(answer)
+	for i, b := range want {
+		switch b {
+		case '0':
+			want[i] = 'O'
+		case '1':
+			want[i] = 'l'
+		}
+	}
+	if err := c.Check(id, string(want)); err != nil {
+		t.Errorf("Check(%q) = %v, answer %q", want, err, answer)
+	}
+}
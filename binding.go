@@ -0,0 +1,30 @@
+package base64Captcha
+
+import (
+	"context"
+	"crypto/subtle"
+)
+
+// bindingKey is the context key of the client binding.
+type bindingKey struct{}
+
+// ContextWithBinding returns a context carrying the binding of the client,
+// for example its session id or IP prefix. A captcha generated with
+// Captcha.GenerateContext under a binding only verifies with
+// Captcha.VerifyContext under the same binding, so that an id solved by one
+// client cannot be replayed by another. Join several values with a
+// separator to bind to all of them.
+func ContextWithBinding(ctx context.Context, binding string) context.Context {
+	return context.WithValue(ctx, bindingKey{}, binding)
+}
+
+// bindingFrom returns the binding carried by ctx.
+func bindingFrom(ctx context.Context) string {
+	binding, _ := ctx.Value(bindingKey{}).(string)
+	return binding
+}
+
+// bindingsEqual compares two bindings in constant time.
+func bindingsEqual(stored, binding string) bool {
+	return subtle.ConstantTimeCompare([]byte(stored), []byte(binding)) == 1
+}
@@ -0,0 +1,75 @@
+package base64Captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestContextWithBinding(t *testing.T) {
+	ctx := context.Background()
+	if got := bindingFrom(ctx); got != "" {
+		t.Errorf("bindingFrom() = %q, want empty", got)
+	}
+	if got := bindingFrom(ContextWithBinding(ctx, "session|10.0.0")); got != "session|10.0.0" {
+		t.Errorf("bindingFrom() = %q, want %q", got, "session|10.0.0")
+	}
+}
+
+func TestCaptcha_Binding(t *testing.T) {
+	stores := map[string]StoreContext{
+		"memory":   NewMemoryStore(10, time.Hour).(StoreContext),
+		"sync map": NewStoreSyncMap(time.Hour),
+		"hash":     NewHashStore(NewMemoryStore(10, time.Hour), []byte("k")).(StoreContext),
+		"sharded":  NewShardedMemoryStore(2, 10, time.Hour).(StoreContext),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := NewCaptchaContext(DefaultDriverDigit, store)
+			human := ContextWithBinding(context.Background(), "session-a")
+			bot := ContextWithBinding(context.Background(), "session-b")
+
+			id, _, answer, err := c.GenerateContext(human)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := c.CheckContext(bot, id, answer, false); err != ErrBindingMismatch {
+				t.Errorf("CheckContext() from another client = %v, want %v", err, ErrBindingMismatch)
+			}
+			if c.Verify(id, answer, false) {
+				t.Error("Verify() without binding verified a bound captcha")
+			}
+			if ok, err := c.VerifyContext(human, id, answer, true); !ok || err != nil {
+				t.Errorf("VerifyContext() = %v, %v, want true, nil", ok, err)
+			}
+
+			// Another client cannot replace the captcha of the human.
+			id, _, answer, _ = c.GenerateContext(human)
+			if _, _, err := c.ReloadContext(bot, id); err != ErrBindingMismatch {
+				t.Errorf("ReloadContext() from another client = %v, want %v", err, ErrBindingMismatch)
+			}
+			if ok, err := c.VerifyContext(human, id, answer, true); !ok || err != nil {
+				t.Errorf("VerifyContext() after a reload from another client = %v, %v, want true, nil", ok, err)
+			}
+			if _, _, err := c.ReloadContext(human, id); err != ErrNotFound {
+				t.Errorf("ReloadContext() of a used captcha = %v, want %v", err, ErrNotFound)
+			}
+
+			// A mismatched binding counts as a wrong answer.
+			c.MaxAttempts = 1
+			id, _, answer, _ = c.GenerateContext(human)
+			if err := c.CheckContext(bot, id, answer, true); err != ErrTooManyAttempts {
+				t.Errorf("CheckContext() = %v, want %v", err, ErrTooManyAttempts)
+			}
+			if err := c.CheckContext(human, id, answer, true); err != ErrTooManyAttempts {
+				t.Errorf("CheckContext() = %v, want %v", err, ErrTooManyAttempts)
+			}
+		})
+	}
+
+	c := NewCaptcha(DefaultDriverDigit, &legacyStore{m: map[string]string{}})
+	ctx := ContextWithBinding(context.Background(), "session-a")
+	if _, _, _, err := c.GenerateContext(ctx); err != ErrRecordUnsupported {
+		t.Errorf("GenerateContext() error = %v, want %v", err, ErrRecordUnsupported)
+	}
+}
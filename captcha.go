@@ -16,12 +16,45 @@
 // base64Captcha is used for fast development of RESTful APIs, web apps and backend services in Go. give a string identifier to the package and it returns with a base64-encoding-png-string
 package base64Captcha
 
-import "strings"
+import (
+	"context"
+	"time"
+)
 
 // Captcha captcha basic information.
 type Captcha struct {
 	Driver Driver
 	Store  Store
+	// StoreContext is used by the context-aware methods. When it is nil,
+	// Store is adapted with NewStoreContext.
+	StoreContext StoreContext
+	// MaxAttempts is the number of wrong answers accepted before a captcha
+	// is invalidated. While it is positive, a wrong answer does not consume
+	// the captcha, so users can fix a typo without opening a guessing
+	// oracle. The store must support records, see Record.
+	MaxAttempts int
+	// Normalizer and Comparer override the answer policy of the store while
+	// either of them is set, see AnswerPolicy.
+	Normalizer AnswerNormalizer
+	Comparer   Comparer
+	// Observer receives the events of the captcha, see NewExpvarObserver.
+	Observer Observer
+	// RenderLimiter bounds the number of captchas rendered at the same
+	// time, see NewRenderLimiter.
+	RenderLimiter *RenderLimiter
+	// RateLimiter limits the captchas generated by every client, which is
+	// identified by the key passed with ContextWithClientKey. Requests
+	// without a client key are not limited.
+	RateLimiter *RateLimiter
+	// PassTTL is the lifetime of the pass tokens issued by
+	// VerifyAndIssuePass, DefaultPassTTL is used when it is zero.
+	PassTTL time.Duration
+	// Difficulty picks the driver of every captcha from the risk of the
+	// client, Driver is used when it is nil.
+	Difficulty DifficultyPolicy
+	// Failures tracks the failed verifications of every client, which
+	// feed the risk passed to Difficulty, see NewFailureTracker.
+	Failures *FailureTracker
 }
 
 // NewCaptcha creates a captcha instance from driver and store
@@ -29,22 +62,122 @@ func NewCaptcha(driver Driver, store Store) *Captcha {
 	return &Captcha{Driver: driver, Store: store}
 }
 
+// NewCaptchaContext creates a captcha instance from driver and a context-aware store.
+func NewCaptchaContext(driver Driver, store StoreContext) *Captcha {
+	return &Captcha{Driver: driver, StoreContext: store}
+}
+
 // Generate generates a random id, base64 image string or an error if any
 func (c *Captcha) Generate() (id, b64s, answer string, err error) {
-	id, content, answer, err := c.Driver.GenerateIdQuestionAnswer()
+	return c.GenerateContext(context.Background())
+}
+
+// GenerateContext generates a random id, base64 image string or an error if any.
+// The context is passed to the store, so a canceled request does not leave
+// the caller waiting on a slow store. The captcha is bound to the binding
+// carried by the context, see ContextWithBinding.
+func (c *Captcha) GenerateContext(ctx context.Context) (id, b64s, answer string, err error) {
+	res, err := c.GenerateResultContext(ctx)
 	if err != nil {
 		return "", "", "", err
 	}
-	item, err := c.Driver.DrawCaptcha(content)
+	b64s, err = res.DataURI()
 	if err != nil {
 		return "", "", "", err
 	}
-	err = c.Store.Set(id, answer)
+	return res.ID, b64s, res.Answer, nil
+}
+
+// Reload draws a new challenge for a captcha which is still live, keeping its
+// id, so that forms holding the id do not need to be updated. The stored
+// answer is replaced and the expiration starts over. It returns ErrNotFound
+// if the captcha is unknown, expired or already used, and
+// ErrReloadUnsupported if the driver does not implement SpecificIdDriver.
+func (c *Captcha) Reload(id string) (b64s, answer string, err error) {
+	return c.ReloadContext(context.Background(), id)
+}
+
+// ReloadContext is like Reload, but it passes the context to the store. The
+// reloaded captcha is bound to the binding carried by the context. If the
+// store implements ReplaceStore, the captcha is replaced only if it is
+// still live, and a captcha bound to another client is not reloaded and
+// ErrBindingMismatch is returned.
+func (c *Captcha) ReloadContext(ctx context.Context, id string) (b64s, answer string, err error) {
+	driver := c.driver(ctx)
+	if _, ok := driver.(SpecificIdDriver); !ok {
+		return "", "", ErrReloadUnsupported
+	}
+	if isPassId(id) {
+		return "", "", ErrNotFound
+	}
+	start := time.Now()
+	if err := c.allow(ctx); err != nil {
+		return "", "", err
+	}
+	store := c.storeContext()
+	if err := c.reloadable(ctx, store, id); err != nil {
+		if !isVerifyOutcome(err) {
+			c.observeStoreError(driverName(driver), "reload", err)
+		}
+		return "", "", err
+	}
+	res, draw, err := c.draw(ctx, driver, id)
 	if err != nil {
-		return "", "", "", err
+		return "", "", err
 	}
-	b64s = item.EncodeB64string()
-	return
+	if err := c.replace(ctx, store, id, c.record(ctx, driver, res.Answer)); err != nil {
+		if !isVerifyOutcome(err) {
+			c.observeStoreError(driverName(driver), "reload", err)
+		}
+		return "", "", err
+	}
+	c.observeGenerated(driver, start, draw)
+	b64s, err = res.DataURI()
+	if err != nil {
+		return "", "", err
+	}
+	return b64s, res.Answer, nil
+}
+
+// draw generates and draws a captcha with driver, under the given id if it is
+// not empty. With a Pool as driver, a captcha rendered ahead of time is used
+// if there is one. Otherwise it waits for the RenderLimiter of the captcha. It
+// also returns the time taken by DrawCaptcha.
+func (c *Captcha) draw(ctx context.Context, driver Driver, id string) (_ *GenerateResult, draw time.Duration, err error) {
+	if p, ok := driver.(*Pool); ok {
+		if pc, ok := p.take(); ok {
+			if id == "" {
+				id = RandomId()
+			}
+			res := newGenerateResult(id, pc.answer, pc.item)
+			res.dataURI = pc.b64s
+			return res, pc.draw, nil
+		}
+	}
+	if c.RenderLimiter != nil {
+		if err := c.RenderLimiter.acquire(ctx); err != nil {
+			return nil, 0, err
+		}
+		defer c.RenderLimiter.release()
+	}
+	var content, answer string
+	if id == "" {
+		id, content, answer, err = driver.GenerateIdQuestionAnswer()
+	} else if d, ok := driver.(SpecificIdDriver); ok {
+		id, content, answer, err = d.GenerateSpecificIdQuestionAnswer(id)
+	} else {
+		err = ErrReloadUnsupported
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	start := time.Now()
+	item, err := driver.DrawCaptcha(content)
+	if err != nil {
+		return nil, 0, err
+	}
+	draw = time.Since(start)
+	return newGenerateResult(id, answer, item), draw, nil
 }
 
 // Verify by a given id key and remove the captcha value in store,
@@ -52,8 +185,101 @@ func (c *Captcha) Generate() (id, b64s, answer string, err error) {
 // if you has multiple captcha instances which share a same store.
 // You may want to call `store.Verify` method instead.
 func (c *Captcha) Verify(id, answer string, clear bool) (match bool) {
-	vv := c.Store.Get(id, clear)
-	vv = strings.TrimSpace(vv)
-	answer = strings.TrimSpace(answer)
-	return strings.EqualFold(vv, answer)
+	match, _ = c.VerifyContext(context.Background(), id, answer, clear)
+	return
+}
+
+// VerifyContext is like Verify, but it reports store failures as an error,
+// so callers can tell a wrong answer apart from an unavailable store. A
+// captcha generated under a binding only verifies under the same binding.
+func (c *Captcha) VerifyContext(ctx context.Context, id, answer string, clear bool) (match bool, err error) {
+	err = c.CheckContext(ctx, id, answer, clear)
+	if err == nil {
+		return true, nil
+	}
+	if isVerifyOutcome(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// Check verifies the answer like Verify, but tells why the verification
+// failed: it returns nil on a match, or one of ErrNotFound, ErrExpired,
+// ErrMismatch, ErrAlreadyUsed, ErrTooManyAttempts and ErrBindingMismatch, so
+// that callers can ask the user to reload an expired captcha instead of
+// reporting a wrong answer.
+func (c *Captcha) Check(id, answer string, clear bool) error {
+	return c.CheckContext(context.Background(), id, answer, clear)
+}
+
+// CheckContext is like Check, but it passes the context to the store. Errors
+// other than the verification outcomes come from the store.
+func (c *Captcha) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	start := time.Now()
+	var driver string
+	err := ErrNotFound
+	if !isPassId(id) {
+		err = c.storeContext().CheckContext(withRecordDriver(c.answerContext(ctx), &driver), id, answer, clear)
+	}
+	c.observeVerified(driver, start, err)
+	c.trackFailure(ctx, err)
+	return err
+}
+
+// allow applies the rate limiter of the captcha to the client of ctx.
+func (c *Captcha) allow(ctx context.Context) error {
+	if c.RateLimiter == nil {
+		return nil
+	}
+	if key := clientKeyFrom(ctx); key != "" && !c.RateLimiter.Allow(key) {
+		return ErrRateLimited
+	}
+	return nil
+}
+
+// reloadable returns nil if captcha id is live, so that no challenge is
+// drawn for a captcha which cannot be reloaded, and ErrNotFound otherwise.
+func (c *Captcha) reloadable(ctx context.Context, store StoreContext, id string) error {
+	v, err := store.GetContext(ctx, id, false)
+	if err == nil && v == "" {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// replace stores the record of a reloaded captcha. With a ReplaceStore it
+// is replaced only if the captcha is still live and bound to the client of
+// ctx, and ErrNotFound or ErrBindingMismatch is returned otherwise.
+func (c *Captcha) replace(ctx context.Context, store StoreContext, id string, rec Record) error {
+	rs, ok := store.(ReplaceStore)
+	if !ok {
+		return store.SetRecordContext(c.answerContext(ctx), id, rec)
+	}
+	err := rs.ReplaceRecordContext(c.answerContext(ctx), id, rec)
+	if err != nil && err != ErrBindingMismatch && isVerifyOutcome(err) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// record returns the record stored for an answer drawn by driver, bound to
+// the client of ctx.
+func (c *Captcha) record(ctx context.Context, driver Driver, answer string) Record {
+	return Record{Answer: answer, MaxAttempts: c.MaxAttempts, Binding: bindingFrom(ctx), Driver: driverName(driver)}
+}
+
+// answerContext passes the answer policy of the captcha to the store.
+func (c *Captcha) answerContext(ctx context.Context) context.Context {
+	if c.Normalizer == nil && c.Comparer == nil {
+		return ctx
+	}
+	return withAnswerPolicy(ctx, AnswerPolicy{Normalizer: c.Normalizer, Comparer: c.Comparer})
+}
+
+// storeContext returns the context-aware store of the captcha.
+func (c *Captcha) storeContext() StoreContext {
+	if c.StoreContext != nil {
+		return c.StoreContext
+	}
+	return NewStoreContext(c.Store)
 }
@@ -0,0 +1,72 @@
+package base64Captcha
+
+import (
+	"context"
+	"time"
+)
+
+// GenerateAccessible is like Generate, but it also renders the captcha as
+// audio with the audio driver, for visitors who cannot see the image. The
+// image and the audio share the id and the answer, so the captcha verifies
+// the same way whichever was solved. A nil audio driver uses
+// DefaultDriverAudio, its Length is ignored.
+//
+// The content of the image must be the digits of the answer, as drawn by
+// DriverDigit or a DriverString of TxtNumbers, other drivers fail with
+// ErrAudioUnsupported. A Pool driver is bypassed, since pooled captchas are
+// not rendered as audio.
+func (c *Captcha) GenerateAccessible(audio *DriverAudio) (id, imageB64s, audioB64s, answer string, err error) {
+	return c.GenerateAccessibleContext(context.Background(), audio)
+}
+
+// GenerateAccessibleContext is like GenerateAccessible, but it passes the
+// context to the store, the limiters and the binding like GenerateContext.
+func (c *Captcha) GenerateAccessibleContext(ctx context.Context, audio *DriverAudio) (id, imageB64s, audioB64s, answer string, err error) {
+	start := time.Now()
+	if err := c.allow(ctx); err != nil {
+		return "", "", "", "", err
+	}
+	if audio == nil {
+		audio = DefaultDriverAudio
+	}
+	driver := c.driver(ctx)
+	if p, ok := driver.(*Pool); ok {
+		driver = p.Driver
+	}
+	if c.RenderLimiter != nil {
+		if err := c.RenderLimiter.acquire(ctx); err != nil {
+			return "", "", "", "", err
+		}
+		defer c.RenderLimiter.release()
+	}
+	id, content, answer, err := driver.GenerateIdQuestionAnswer()
+	if err != nil {
+		return "", "", "", "", err
+	}
+	if _, err := parseDigits(content); err != nil || content != answer {
+		return "", "", "", "", ErrAudioUnsupported
+	}
+	drawStart := time.Now()
+	image, err := driver.DrawCaptcha(content)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	sound, err := audio.DrawCaptcha(content)
+	if err != nil {
+		return "", "", "", "", err
+	}
+	draw := time.Since(drawStart)
+	if imageB64s, err = image.EncodeB64string(); err != nil {
+		return "", "", "", "", err
+	}
+	if audioB64s, err = sound.EncodeB64string(); err != nil {
+		return "", "", "", "", err
+	}
+	err = c.storeContext().SetRecordContext(c.answerContext(ctx), id, c.record(ctx, driver, answer))
+	if err != nil {
+		c.observeStoreError(driverName(driver), "generate", err)
+		return "", "", "", "", err
+	}
+	c.observeGenerated(driver, start, draw)
+	return id, imageB64s, audioB64s, answer, nil
+}
@@ -0,0 +1,76 @@
+package base64Captcha
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestCaptcha_GenerateAccessible(t *testing.T) {
+	drivers := map[string]Driver{
+		"digit":  DefaultDriverDigit,
+		"string": NewDriverString(80, 240, 0, 0, 4, TxtNumbers, nil, nil, nil),
+		"pool":   NewPool(DefaultDriverDigit, 1, 1),
+	}
+	for name, driver := range drivers {
+		t.Run(name, func(t *testing.T) {
+			c := NewCaptcha(driver, NewMemoryStore(10, Expiration))
+			id, image, audio, answer, err := c.GenerateAccessible(NewDriverAudio(0, "zh"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !strings.HasPrefix(image, "data:"+MimeTypeImage+";base64,") {
+				t.Errorf("image = %.40s...", image)
+			}
+			if !strings.HasPrefix(audio, "data:"+MimeTypeAudio+";base64,") {
+				t.Errorf("audio = %.40s...", audio)
+			}
+			if !c.Verify(id, answer, true) {
+				t.Error("the answer failed")
+			}
+		})
+	}
+}
+
+func TestCaptcha_GenerateAccessible_unsupported(t *testing.T) {
+	for name, driver := range map[string]Driver{
+		"math":   NewDriverMath(80, 240, 0, 0, nil, nil, nil),
+		"string": NewDriverString(80, 240, 0, 0, 4, TxtAlphabet, nil, nil, nil),
+	} {
+		c := NewCaptcha(driver, NewMemoryStore(10, Expiration))
+		if _, _, _, _, err := c.GenerateAccessible(nil); !errors.Is(err, ErrAudioUnsupported) {
+			t.Errorf("%s: GenerateAccessible() error = %v, want ErrAudioUnsupported", name, err)
+		}
+	}
+}
+
+func TestCaptcha_GenerateAccessibleContext_binding(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	ctx := ContextWithBinding(context.Background(), "session")
+	id, _, _, answer, err := c.GenerateAccessibleContext(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.CheckContext(context.Background(), id, answer, false); !errors.Is(err, ErrBindingMismatch) {
+		t.Errorf("CheckContext() without the binding error = %v, want ErrBindingMismatch", err)
+	}
+	if err := c.CheckContext(ctx, id, answer, true); err != nil {
+		t.Errorf("CheckContext() error = %v", err)
+	}
+}
+
+func TestCaptcha_GenerateAccessibleContext_difficulty(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	c.Difficulty = TieredDifficulty{
+		{Driver: NewDriverDigit(80, 240, 4, 0.3, 20)},
+		{MinScore: 0.5, Driver: NewDriverDigit(80, 240, 7, 0.7, 80)},
+	}
+	_, _, _, answer, err := c.GenerateAccessibleContext(ContextWithRiskScore(context.Background(), 0.9), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(answer) != 7 {
+		t.Errorf("answer %q not drawn by the driver of the risk", answer)
+	}
+}
@@ -0,0 +1,89 @@
+package base64Captcha
+
+import (
+	"context"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultPassTTL is the lifetime of pass tokens when Captcha.PassTTL is zero.
+var DefaultPassTTL = 2 * time.Minute
+
+// passIdPrefix prefixes the store ids of pass tokens, so that they never
+// collide with captcha ids.
+const passIdPrefix = "pass:"
+
+// isPassId reports whether id is the store id of a pass token, which
+// Check and Reload must not reach.
+func isPassId(id string) bool {
+	return strings.HasPrefix(id, passIdPrefix)
+}
+
+// VerifyAndIssuePass verifies and clears the captcha, and on a match returns
+// a single-use pass token to be redeemed with RedeemPass, for flows which
+// check the captcha in one request and submit the form in another. A failed
+// verification returns the error of Check.
+//
+// The pass is kept in the store of the captcha and lives for PassTTL, but
+// no longer than the store keeps its values.
+func (c *Captcha) VerifyAndIssuePass(id, answer string) (pass string, err error) {
+	return c.VerifyAndIssuePassContext(context.Background(), id, answer)
+}
+
+// VerifyAndIssuePassContext is like VerifyAndIssuePass, but it passes the
+// context to the store. The pass is bound to the binding carried by the
+// context, like the captcha.
+func (c *Captcha) VerifyAndIssuePassContext(ctx context.Context, id, answer string) (pass string, err error) {
+	if err := c.CheckContext(ctx, id, answer, true); err != nil {
+		return "", err
+	}
+	ttl := c.PassTTL
+	if ttl <= 0 {
+		ttl = DefaultPassTTL
+	}
+	passId := RandomId()
+	exp := strconv.FormatInt(time.Now().Add(ttl).Unix(), 36)
+	secret := hex.EncodeToString(randomBytes(16))
+	// The expiry is part of the stored answer, so it cannot be extended by
+	// editing the token.
+	rec := Record{Answer: exp + "." + secret, Binding: bindingFrom(ctx)}
+	err = c.storeContext().SetRecordContext(withAnswerPolicy(ctx, exactAnswerPolicy), passIdPrefix+passId, rec)
+	if err != nil {
+		c.observeStoreError("", "pass", err)
+		return "", err
+	}
+	return passId + "." + exp + "." + secret, nil
+}
+
+// RedeemPass consumes a pass token issued by VerifyAndIssuePass. It returns
+// nil for a valid pass, ErrInvalidToken for a malformed token, or one of the
+// errors of Check, such as ErrAlreadyUsed for a pass redeemed before.
+func (c *Captcha) RedeemPass(pass string) error {
+	return c.RedeemPassContext(context.Background(), pass)
+}
+
+// RedeemPassContext is like RedeemPass, but it passes the context to the
+// store. A bound pass is only redeemed under the same binding.
+func (c *Captcha) RedeemPassContext(ctx context.Context, pass string) error {
+	parts := strings.Split(pass, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(parts[1], 36, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	err = c.storeContext().CheckContext(withAnswerPolicy(ctx, exactAnswerPolicy), passIdPrefix+parts[0], parts[1]+"."+parts[2], true)
+	if err != nil {
+		if !isVerifyOutcome(err) {
+			c.observeStoreError("", "pass", err)
+		}
+		return err
+	}
+	if time.Now().After(time.Unix(unix, 0)) {
+		return ErrExpired
+	}
+	return nil
+}
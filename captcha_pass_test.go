@@ -0,0 +1,89 @@
+package base64Captcha
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptcha_VerifyAndIssuePass(t *testing.T) {
+	for _, store := range []Store{NewMemoryStore(10, time.Hour), &legacyStore{m: map[string]string{}}, NewHashStore(NewMemoryStore(10, time.Hour), []byte("k"))} {
+		c := NewCaptcha(DefaultDriverDigit, store)
+		id, _, answer, err := c.Generate()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.VerifyAndIssuePass(id, answer+"0"); err != ErrMismatch {
+			t.Errorf("%T: VerifyAndIssuePass() of a wrong answer error = %v, want %v", store, err, ErrMismatch)
+		}
+
+		id, _, answer, _ = c.Generate()
+		pass, err := c.VerifyAndIssuePass(id, answer)
+		if err != nil || pass == "" {
+			t.Fatalf("%T: VerifyAndIssuePass() = %q, %v", store, pass, err)
+		}
+		if c.Verify(id, answer, false) {
+			t.Errorf("%T: the captcha was not cleared", store)
+		}
+		if err := c.RedeemPass(pass); err != nil {
+			t.Errorf("%T: RedeemPass() = %v", store, err)
+		}
+		if err := c.RedeemPass(pass); err == nil {
+			t.Errorf("%T: a pass was redeemed twice", store)
+		}
+	}
+}
+
+func TestCaptcha_RedeemPass(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, time.Hour))
+	issue := func(ctx context.Context) string {
+		id, _, answer, _ := c.GenerateContext(ctx)
+		pass, err := c.VerifyAndIssuePassContext(ctx, id, answer)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return pass
+	}
+
+	for _, pass := range []string{"", "abc", "a.b.c.d", ".1.2", "id.!.secret"} {
+		if err := c.RedeemPass(pass); err != ErrInvalidToken {
+			t.Errorf("RedeemPass(%q) = %v, want %v", pass, err, ErrInvalidToken)
+		}
+	}
+
+	pass := issue(context.Background())
+	parts := strings.Split(pass, ".")
+	forged := parts[0] + "." + strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 36) + "." + parts[2]
+	if err := c.RedeemPass(forged); err != ErrMismatch {
+		t.Errorf("RedeemPass() with an extended expiry = %v, want %v", err, ErrMismatch)
+	}
+
+	// Expiries have a resolution of a second, so this one is in the past.
+	c.PassTTL = time.Nanosecond
+	pass = issue(context.Background())
+	if err := c.RedeemPass(pass); err != ErrExpired {
+		t.Errorf("RedeemPass() of an expired pass = %v, want %v", err, ErrExpired)
+	}
+
+	c.PassTTL = 0
+	human := ContextWithBinding(context.Background(), "a")
+	pass = issue(human)
+	if err := c.RedeemPassContext(ContextWithBinding(context.Background(), "b"), pass); err != ErrBindingMismatch {
+		t.Errorf("RedeemPassContext() from another client = %v, want %v", err, ErrBindingMismatch)
+	}
+
+	// Passes are not captchas, Check and Reload cannot reach them.
+	pass = issue(context.Background())
+	parts = strings.Split(pass, ".")
+	if err := c.Check(passIdPrefix+parts[0], parts[1]+"."+parts[2], true); err != ErrNotFound {
+		t.Errorf("Check() of a pass = %v, want %v", err, ErrNotFound)
+	}
+	if _, _, err := c.Reload(passIdPrefix + parts[0]); err != ErrNotFound {
+		t.Errorf("Reload() of a pass = %v, want %v", err, ErrNotFound)
+	}
+	if err := c.RedeemPass(pass); err != nil {
+		t.Errorf("RedeemPass() after Check and Reload = %v", err)
+	}
+}
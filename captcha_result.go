@@ -0,0 +1,140 @@
+package base64Captcha
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"time"
+)
+
+// GenerateResult is a generated captcha with its metadata.
+type GenerateResult struct {
+	// ID is the id of the captcha.
+	ID string
+	// Answer is the solution of the captcha, it is not marshaled.
+	Answer string
+	// Item is the rendered captcha.
+	Item Item
+	// MIMEType is the content type of the item, such as "image/png".
+	MIMEType string
+	// Width and Height are the size of an image in pixels, zero for audio.
+	Width  int
+	Height int
+	// Duration is the length of a sound, zero for images.
+	Duration time.Duration
+	// ExpiresAt is when the store forgets the captcha, zero if the store
+	// does not implement ExpiringStore.
+	ExpiresAt time.Time
+
+	// dataURI caches the data URI of the item.
+	dataURI string
+}
+
+// newGenerateResult returns the result of a captcha drawn as item.
+func newGenerateResult(id, answer string, item Item) *GenerateResult {
+	res := &GenerateResult{ID: id, Answer: answer, Item: item, MIMEType: item.ContentType()}
+	if img, ok := item.(ImageItem); ok {
+		res.Width, res.Height = img.Width(), img.Height()
+	}
+	if snd, ok := item.(AudioItem); ok {
+		res.Duration = snd.Duration()
+	}
+	return res
+}
+
+// Bytes returns the encoded item, such as a PNG or a WAV file.
+func (r *GenerateResult) Bytes() ([]byte, error) {
+	return encodeBinary(r.Item)
+}
+
+// DataURI returns the item as a base64 data URI, as returned by Generate.
+func (r *GenerateResult) DataURI() (string, error) {
+	if r.dataURI == "" {
+		s, err := r.Item.EncodeB64string()
+		if err != nil {
+			return "", err
+		}
+		r.dataURI = s
+	}
+	return r.dataURI, nil
+}
+
+// WriteDataURI writes the item to w as a base64 data URI, without holding
+// the whole URI in memory.
+func (r *GenerateResult) WriteDataURI(w io.Writer) (int64, error) {
+	if r.dataURI != "" {
+		n, err := io.WriteString(w, r.dataURI)
+		return int64(n), err
+	}
+	return r.Item.WriteDataURI(w)
+}
+
+// MarshalJSON marshals the result for an API response, with the item as a
+// data URI:
+//
+//	{"id": "...", "mimeType": "image/png", "width": 240, "height": 80,
+//	 "expiresAt": "2006-01-02T15:04:05Z", "data": "data:image/png;base64,..."}
+//
+// Sounds have a "durationMs" instead of a size. The answer is left out.
+func (r *GenerateResult) MarshalJSON() ([]byte, error) {
+	data, err := r.DataURI()
+	if err != nil {
+		return nil, err
+	}
+	var expiresAt *time.Time
+	if !r.ExpiresAt.IsZero() {
+		expiresAt = &r.ExpiresAt
+	}
+	return json.Marshal(struct {
+		ID         string     `json:"id"`
+		MIMEType   string     `json:"mimeType"`
+		Width      int        `json:"width,omitempty"`
+		Height     int        `json:"height,omitempty"`
+		DurationMs int64      `json:"durationMs,omitempty"`
+		ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+		Data       string     `json:"data"`
+	}{r.ID, r.MIMEType, r.Width, r.Height, r.Duration.Milliseconds(), expiresAt, data})
+}
+
+// GenerateResult is like Generate, but it returns the captcha with its
+// metadata.
+func (c *Captcha) GenerateResult() (*GenerateResult, error) {
+	return c.GenerateResultContext(context.Background())
+}
+
+// GenerateResultContext is like GenerateContext, but it returns the captcha
+// with its metadata.
+func (c *Captcha) GenerateResultContext(ctx context.Context) (*GenerateResult, error) {
+	start := time.Now()
+	if err := c.allow(ctx); err != nil {
+		return nil, err
+	}
+	driver := c.driver(ctx)
+	res, draw, err := c.draw(ctx, driver, "")
+	if err != nil {
+		return nil, err
+	}
+	err = c.storeContext().SetRecordContext(c.answerContext(ctx), res.ID, c.record(ctx, driver, res.Answer))
+	if err != nil {
+		c.observeStoreError(driverName(driver), "generate", err)
+		return nil, err
+	}
+	if exp := c.expiration(); exp > 0 {
+		res.ExpiresAt = time.Now().Add(exp)
+	}
+	c.observeGenerated(driver, start, draw)
+	return res, nil
+}
+
+// expiration returns how long the store of the captcha keeps captchas, or
+// zero if it does not tell.
+func (c *Captcha) expiration() time.Duration {
+	var store interface{} = c.StoreContext
+	if store == nil {
+		store = c.Store
+	}
+	if e, ok := store.(ExpiringStore); ok {
+		return e.Expiration()
+	}
+	return 0
+}
@@ -0,0 +1,104 @@
+package base64Captcha
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptcha_GenerateResult(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	before := time.Now()
+	res, err := c.GenerateResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MIMEType != MimeTypeImage || res.Width != 240 || res.Height != 80 || res.Duration != 0 {
+		t.Errorf("result = %+v", res)
+	}
+	if res.ExpiresAt.Before(before.Add(Expiration)) || res.ExpiresAt.After(time.Now().Add(Expiration)) {
+		t.Errorf("ExpiresAt = %v, want about %v", res.ExpiresAt, before.Add(Expiration))
+	}
+	b, err := res.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(b, []byte("\x89PNG")) {
+		t.Errorf("Bytes() = %q..., want a PNG", b[:8])
+	}
+	uri, err := res.DataURI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(uri, "data:image/png;base64,") {
+		t.Errorf("DataURI() = %.40s...", uri)
+	}
+	var buf bytes.Buffer
+	if _, err := res.WriteDataURI(&buf); err != nil || buf.String() != uri {
+		t.Errorf("WriteDataURI() = %.40s..., %v, want the data URI", buf.String(), err)
+	}
+	if !c.Verify(res.ID, res.Answer, true) {
+		t.Error("the answer failed")
+	}
+
+	j, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]interface{}
+	if err := json.Unmarshal(j, &got); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"id", "mimeType", "width", "height", "expiresAt", "data"} {
+		if _, ok := got[key]; !ok {
+			t.Errorf("JSON %s has no %q", j[:80], key)
+		}
+	}
+	if _, ok := got["durationMs"]; ok {
+		t.Error("JSON of an image has a duration")
+	}
+	if _, ok := got["Answer"]; ok {
+		t.Error("JSON holds the answer")
+	}
+}
+
+func TestCaptcha_GenerateResult_audio(t *testing.T) {
+	c := NewCaptcha(NewDriverAudio(4, "en"), &legacyStore{m: map[string]string{}})
+	res, err := c.GenerateResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.MIMEType != MimeTypeAudio || res.Duration <= 0 || res.Width != 0 {
+		t.Errorf("result = %+v", res)
+	}
+	if !res.ExpiresAt.IsZero() {
+		t.Errorf("ExpiresAt = %v for a store without expiration", res.ExpiresAt)
+	}
+	j, err := json.Marshal(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(j, []byte(`"durationMs"`)) || bytes.Contains(j, []byte(`"expiresAt"`)) {
+		t.Errorf("JSON = %.120s...", j)
+	}
+}
+
+func TestCaptcha_GenerateResult_pool(t *testing.T) {
+	p := NewPool(DefaultDriverDigit, 1, 1)
+	p.Start()
+	defer p.Stop()
+	waitDepth(t, p, 1)
+	c := NewCaptcha(p, NewMemoryStore(10, Expiration))
+	res, err := c.GenerateResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := res.Item.(*ItemDigit); !ok || res.Width != 240 {
+		t.Errorf("result of the pool = %+v", res)
+	}
+	if p.Stats().Hits != 1 {
+		t.Errorf("Stats() = %+v, want a hit", p.Stats())
+	}
+}
@@ -0,0 +1,225 @@
+package base64Captcha
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const statelessTokenVersion = "1"
+
+// StatelessCaptcha generates captchas that need no shared Store. The id it
+// returns is an opaque token carrying a keyed hash of the answer, an expiry
+// and a nonce, sealed with HMAC-SHA256. Verification checks the signature and
+// the expiry, then records the nonce in a small local replay cache, so a
+// token is accepted once per process.
+//
+// Keys are identified in the token by a key ID derived from the key, so keys
+// can be rotated with RotateKey while tokens signed with older keys stay
+// valid until they are retired with RetireKey.
+type StatelessCaptcha struct {
+	Driver Driver
+	// TTL is how long a token stays valid, Expiration if it is not
+	// positive.
+	TTL time.Duration
+	// Normalizer is applied to the answer before it is sealed into the token
+	// and to the submitted answer, it defaults to DefaultNormalizer. Answers
+	// are compared by their keyed hashes, so there is no Comparer.
+	Normalizer AnswerNormalizer
+
+	mu      sync.RWMutex
+	keys    map[string][]byte
+	current string
+	replay  replayCache
+}
+
+// NewStatelessCaptcha creates a stateless captcha instance from driver, a
+// secret signing key and the lifetime of the tokens, Expiration if ttl is
+// not positive.
+func NewStatelessCaptcha(driver Driver, key []byte, ttl time.Duration) *StatelessCaptcha {
+	if ttl <= 0 {
+		ttl = Expiration
+	}
+	c := &StatelessCaptcha{Driver: driver, TTL: ttl, keys: make(map[string][]byte)}
+	c.RotateKey(key)
+	return c
+}
+
+// RotateKey makes key the signing key of new tokens. Previous keys are kept
+// to verify tokens issued before the rotation.
+func (c *StatelessCaptcha) RotateKey(key []byte) {
+	kid := statelessKeyID(key)
+	c.mu.Lock()
+	c.keys[kid] = append([]byte(nil), key...)
+	c.current = kid
+	c.mu.Unlock()
+}
+
+// RetireKey forgets a previous key, tokens signed with it become invalid.
+// The current signing key cannot be retired.
+func (c *StatelessCaptcha) RetireKey(key []byte) {
+	kid := statelessKeyID(key)
+	c.mu.Lock()
+	if kid != c.current {
+		delete(c.keys, kid)
+	}
+	c.mu.Unlock()
+}
+
+// Generate generates a token used as captcha id, base64 image string or an error if any
+func (c *StatelessCaptcha) Generate() (id, b64s, answer string, err error) {
+	_, content, answer, err := c.Driver.GenerateIdQuestionAnswer()
+	if err != nil {
+		return "", "", "", err
+	}
+	item, err := c.Driver.DrawCaptcha(content)
+	if err != nil {
+		return "", "", "", err
+	}
+	ttl := c.TTL
+	if ttl <= 0 {
+		ttl = Expiration
+	}
+	id, err = c.token(answer, time.Now().Add(ttl))
+	if err != nil {
+		return "", "", "", err
+	}
+	b64s, err = item.EncodeB64string()
+	if err != nil {
+		return "", "", "", err
+	}
+	return
+}
+
+// Verify verifies the answer of a token, every token can be verified once.
+func (c *StatelessCaptcha) Verify(id, answer string) bool {
+	return c.Check(id, answer) == nil
+}
+
+// Check is like Verify, but tells why the verification failed: it returns
+// nil on a match, or one of ErrInvalidToken, ErrExpired, ErrAlreadyUsed and
+// ErrMismatch. A wrong answer consumes the token too, otherwise the token
+// would be a guessing oracle until it expires.
+func (c *StatelessCaptcha) Check(id, answer string) error {
+	parts := strings.Split(id, ".")
+	if len(parts) != 6 || parts[0] != statelessTokenVersion {
+		return ErrInvalidToken
+	}
+	kid, exp, nonce, answerTag := parts[1], parts[2], parts[3], parts[4]
+	c.mu.RLock()
+	key, ok := c.keys[kid]
+	c.mu.RUnlock()
+	if !ok {
+		return ErrInvalidToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[5])
+	if err != nil || !hmac.Equal(sig, statelessMAC(key, "token", strings.Join(parts[:5], "."))) {
+		return ErrInvalidToken
+	}
+	unix, err := strconv.ParseInt(exp, 36, 64)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	expiry := time.Unix(unix, 0)
+	now := time.Now()
+	if now.After(expiry) {
+		return ErrExpired
+	}
+	if !c.replay.use(nonce, expiry, now) {
+		return ErrAlreadyUsed
+	}
+	tag, err := base64.RawURLEncoding.DecodeString(answerTag)
+	if err != nil || !hmac.Equal(tag, statelessAnswerTag(key, nonce, c.normalize(answer))) {
+		return ErrMismatch
+	}
+	return nil
+}
+
+// token seals the answer into a token expiring at expiry.
+func (c *StatelessCaptcha) token(answer string, expiry time.Time) (string, error) {
+	b := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, b); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(b)
+	c.mu.RLock()
+	kid, key := c.current, c.keys[c.current]
+	c.mu.RUnlock()
+	if len(key) == 0 {
+		return "", errors.New("captcha: stateless captcha has no signing key")
+	}
+	payload := strings.Join([]string{
+		statelessTokenVersion,
+		kid,
+		strconv.FormatInt(expiry.Unix(), 36),
+		nonce,
+		base64.RawURLEncoding.EncodeToString(statelessAnswerTag(key, nonce, c.normalize(answer))),
+	}, ".")
+	sig := base64.RawURLEncoding.EncodeToString(statelessMAC(key, "token", payload))
+	return payload + "." + sig, nil
+}
+
+// statelessKeyID derives the key ID written in tokens from the key.
+func statelessKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:4])
+}
+
+// normalize normalizes an answer with the normalizer of the captcha.
+func (c *StatelessCaptcha) normalize(answer string) string {
+	return AnswerPolicy{Normalizer: c.Normalizer}.Normalize(answer)
+}
+
+// statelessAnswerTag is the keyed hash of a normalized answer.
+func statelessAnswerTag(key []byte, nonce, answer string) []byte {
+	return statelessMAC(key, "answer", nonce+"."+answer)[:16]
+}
+
+// statelessMAC computes HMAC-SHA256 of msg, domain separated by purpose.
+func statelessMAC(key []byte, purpose, msg string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(purpose))
+	h.Write([]byte{0})
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// replayCache remembers used nonces until their token expires.
+type replayCache struct {
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	nextSweep time.Time
+}
+
+// replaySweepInterval is how often expired nonces are dropped.
+const replaySweepInterval = time.Minute
+
+// use records the nonce and reports whether it was unused.
+func (r *replayCache) use(nonce string, expiry, now time.Time) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.seen == nil {
+		r.seen = make(map[string]time.Time)
+	}
+	if now.After(r.nextSweep) {
+		for n, exp := range r.seen {
+			if now.After(exp) {
+				delete(r.seen, n)
+			}
+		}
+		r.nextSweep = now.Add(replaySweepInterval)
+	}
+	if _, ok := r.seen[nonce]; ok {
+		return false
+	}
+	r.seen[nonce] = expiry
+	return true
+}
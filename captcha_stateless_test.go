@@ -0,0 +1,130 @@
+package base64Captcha
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStatelessCaptcha_Verify(t *testing.T) {
+	c := NewStatelessCaptcha(DefaultDriverDigit, []byte("secret"), time.Minute)
+	id, b64s, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b64s == "" {
+		t.Error("empty b64s")
+	}
+	if strings.Contains(id, answer) {
+		t.Error("token leaks the answer")
+	}
+	if err := c.Check(id, " "+answer+" "); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+	if err := c.Check(id, answer); err != ErrAlreadyUsed {
+		t.Errorf("replay = %v, want %v", err, ErrAlreadyUsed)
+	}
+
+	id, _, answer, _ = c.Generate()
+	if err := c.Check(id, answer+"0"); err != ErrMismatch {
+		t.Errorf("wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if c.Verify(id, answer) {
+		t.Error("a wrong answer must consume the token")
+	}
+}
+
+func TestStatelessCaptcha_InvalidToken(t *testing.T) {
+	c := NewStatelessCaptcha(DefaultDriverDigit, []byte("secret"), time.Minute)
+	id, err := c.token("1234", time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(id, ".")
+	parts[2] = "zzzzzz"
+	tests := []struct {
+		name string
+		id   string
+	}{
+		{"empty", ""},
+		{"garbage", "a.b.c"},
+		{"extended", strings.Join(parts, ".")},
+		{"foreign", func() string {
+			other := NewStatelessCaptcha(DefaultDriverDigit, []byte("other"), time.Minute)
+			id, _ := other.token("1234", time.Now().Add(time.Minute))
+			return id
+		}()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := c.Check(tt.id, "1234"); err != ErrInvalidToken {
+				t.Errorf("Check() = %v, want %v", err, ErrInvalidToken)
+			}
+		})
+	}
+}
+
+func TestStatelessCaptcha_Expired(t *testing.T) {
+	c := NewStatelessCaptcha(DefaultDriverDigit, []byte("secret"), time.Minute)
+	id, err := c.token("1234", time.Now().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(id, "1234"); err != ErrExpired {
+		t.Errorf("Check() = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestNewStatelessCaptcha_defaultTTL(t *testing.T) {
+	c := NewStatelessCaptcha(DefaultDriverDigit, []byte("secret"), 0)
+	if c.TTL != Expiration {
+		t.Errorf("TTL = %v, want %v", c.TTL, Expiration)
+	}
+	c.TTL = 0
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(id, answer); err != nil {
+		t.Errorf("Check() without TTL = %v", err)
+	}
+}
+
+func TestStatelessCaptcha_RotateKey(t *testing.T) {
+	oldKey, newKey := []byte("old"), []byte("new")
+	c := NewStatelessCaptcha(DefaultDriverDigit, oldKey, time.Minute)
+	before, _ := c.token("1234", time.Now().Add(time.Minute))
+	retired, _ := c.token("1234", time.Now().Add(time.Minute))
+	c.RotateKey(newKey)
+	after, _ := c.token("1234", time.Now().Add(time.Minute))
+	if err := c.Check(before, "1234"); err != nil {
+		t.Errorf("token signed before rotation = %v, want nil", err)
+	}
+	if err := c.Check(after, "1234"); err != nil {
+		t.Errorf("token signed after rotation = %v, want nil", err)
+	}
+	c.RetireKey(oldKey)
+	if err := c.Check(retired, "1234"); err != ErrInvalidToken {
+		t.Errorf("token signed with retired key = %v, want %v", err, ErrInvalidToken)
+	}
+	c.RetireKey(newKey)
+	if _, _, _, err := c.Generate(); err != nil {
+		t.Errorf("current key must not be retired: %v", err)
+	}
+}
+
+func Test_replayCache_use(t *testing.T) {
+	var r replayCache
+	now := time.Now()
+	if !r.use("n", now.Add(time.Second), now) {
+		t.Error("first use failed")
+	}
+	if r.use("n", now.Add(time.Second), now) {
+		t.Error("second use succeeded")
+	}
+	later := now.Add(2 * replaySweepInterval)
+	r.use("m", later.Add(time.Second), later)
+	if _, ok := r.seen["n"]; ok {
+		t.Error("expired nonce was not swept")
+	}
+}
@@ -17,10 +17,13 @@
 package base64Captcha
 
 import (
+	"context"
 	"crypto/rand"
+	"errors"
 	"math/big"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func TestCaptcha_GenerateB64s(t *testing.T) {
@@ -29,12 +32,13 @@ func TestCaptcha_GenerateB64s(t *testing.T) {
 		Store  Store
 	}
 
-	dDigit := DriverDigit{80, 240, 5, 0.7, 5}
+	dDigit := DriverDigit{Height: 80, Width: 240, Length: 5, MaxSkew: 0.7, DotCount: 5}
 	n, err := rand.Int(rand.Reader, big.NewInt(5))
 	if err != nil {
 		t.Fatal(err)
 	}
-	audioDriver := NewDriverAudio(int(n.Int64()), "en")
+	// An empty answer never verifies, so use at least one digit.
+	audioDriver := NewDriverAudio(int(n.Int64())+1, "en")
 	tests := []struct {
 		name     string
 		fields   fields
@@ -141,3 +145,166 @@ func TestCaptcha_Generate(t *testing.T) {
 		})
 	}
 }
+
+func TestCaptcha_GenerateContext(t *testing.T) {
+	ctx := context.Background()
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	id, b64s, answer, err := c.GenerateContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id == "" || b64s == "" || answer == "" {
+		t.Fatalf("GenerateContext() = %q, %q, %q", id, b64s, answer)
+	}
+	match, err := c.VerifyContext(ctx, id, answer, true)
+	if err != nil || !match {
+		t.Errorf("VerifyContext() = %v, %v", match, err)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, _, _, err := c.GenerateContext(canceled); !errors.Is(err, context.Canceled) {
+		t.Errorf("GenerateContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestCaptcha_VerifyContextStoreDown(t *testing.T) {
+	c := NewCaptchaContext(DefaultDriverDigit, downStore{})
+	if _, _, _, err := c.Generate(); !errors.Is(err, errStoreDown) {
+		t.Errorf("Generate() error = %v, want %v", err, errStoreDown)
+	}
+	match, err := c.VerifyContext(context.Background(), "id", "answer", true)
+	if match || !errors.Is(err, errStoreDown) {
+		t.Errorf("VerifyContext() = %v, %v, want false, %v", match, err, errStoreDown)
+	}
+}
+
+func TestCaptcha_Check(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Check(id, "x", false); err != ErrMismatch {
+		t.Errorf("Check() = %v, want %v", err, ErrMismatch)
+	}
+	if err := c.Check(id, " "+answer+" ", true); err != nil {
+		t.Errorf("Check() = %v, want nil", err)
+	}
+	if err := c.Check(id, answer, true); err != ErrAlreadyUsed {
+		t.Errorf("Check() = %v, want %v", err, ErrAlreadyUsed)
+	}
+	if c.Verify("unknown", "", true) {
+		t.Error("Verify() of an unknown id with an empty answer must fail")
+	}
+}
+
+func TestCaptcha_MaxAttempts(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	c.MaxAttempts = 2
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Verify(id, "x", true) {
+		t.Fatal("wrong answer verified")
+	}
+	if !c.Verify(id, answer, true) {
+		t.Error("a wrong answer must not consume the captcha")
+	}
+
+	id, _, _, _ = c.Generate()
+	_ = c.Check(id, "x", true)
+	if err := c.Check(id, "y", true); err != ErrTooManyAttempts {
+		t.Errorf("Check() = %v, want %v", err, ErrTooManyAttempts)
+	}
+
+	c = NewCaptcha(DefaultDriverDigit, &legacyStore{m: map[string]string{}})
+	c.MaxAttempts = 2
+	if _, _, _, err := c.Generate(); err != ErrRecordUnsupported {
+		t.Errorf("Generate() error = %v, want %v", err, ErrRecordUnsupported)
+	}
+}
+
+func TestCaptcha_Reload(t *testing.T) {
+	drivers := map[string]Driver{
+		"digit":    DefaultDriverDigit,
+		"audio":    NewDriverAudio(4, "en"),
+		"string":   NewDriverString(80, 240, 0, 0, 4, TxtNumbers+TxtAlphabet, nil, nil, nil),
+		"math":     NewDriverMath(80, 240, 0, 0, nil, nil, nil),
+		"chinese":  NewDriverChinese(80, 240, 0, 0, 2, TxtChineseCharaters, nil, nil, nil),
+		"language": NewDriverLanguage(80, 240, 0, 0, 4, nil, nil, nil, "greek"),
+	}
+	for name, driver := range drivers {
+		t.Run(name, func(t *testing.T) {
+			c := NewCaptcha(driver, NewMemoryStore(10, Expiration))
+			id, _, answer, err := c.Generate()
+			if err != nil {
+				t.Fatal(err)
+			}
+			b64s, reloaded, err := c.Reload(id)
+			if err != nil {
+				t.Fatalf("Reload() error = %v", err)
+			}
+			if b64s == "" {
+				t.Error("Reload() returned no image")
+			}
+			if got := c.Store.Get(id, false); got != reloaded {
+				t.Errorf("stored answer = %q, want %q", got, reloaded)
+			}
+			if reloaded != answer && c.Verify(id, answer, false) {
+				t.Error("the previous answer still verifies")
+			}
+			if !c.Verify(id, reloaded, true) {
+				t.Error("the new answer failed")
+			}
+			if _, _, err := c.Reload(id); err != ErrNotFound {
+				t.Errorf("Reload() of a used captcha error = %v, want %v", err, ErrNotFound)
+			}
+		})
+	}
+
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, Expiration))
+	if _, _, err := c.Reload("unknown"); err != ErrNotFound {
+		t.Errorf("Reload() of an unknown id error = %v, want %v", err, ErrNotFound)
+	}
+	c.Driver = struct{ Driver }{DefaultDriverDigit}
+	id, _, _, _ := c.Generate()
+	if _, _, err := c.Reload(id); err != ErrReloadUnsupported {
+		t.Errorf("Reload() error = %v, want %v", err, ErrReloadUnsupported)
+	}
+}
+
+func TestCaptcha_Reload_consumedMeanwhile(t *testing.T) {
+	stores := map[string]StoreContext{
+		"memory":   NewMemoryStore(10, time.Hour).(StoreContext),
+		"sync map": NewStoreSyncMap(time.Hour),
+		"sharded":  NewShardedMemoryStore(2, 10, time.Hour).(StoreContext),
+		"hash":     NewHashStore(NewMemoryStore(10, time.Hour), []byte("k")).(StoreContext),
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			c := NewCaptchaContext(DefaultDriverDigit, store)
+			c.MaxAttempts = 1
+			ctx := context.Background()
+			for _, spend := range []func(id, answer string){
+				func(id, answer string) { c.Verify(id, answer, true) },
+				func(id, answer string) { c.Verify(id, answer+"0", true) },
+			} {
+				id, _, answer, _ := c.Generate()
+				// The captcha is consumed between the check and the
+				// replacement of a reload.
+				if err := c.reloadable(ctx, store, id); err != nil {
+					t.Fatal(err)
+				}
+				spend(id, answer)
+				if err := c.replace(ctx, store, id, c.record(ctx, DefaultDriverDigit, "1234")); err != ErrNotFound {
+					t.Errorf("replace() of a spent captcha = %v, want %v", err, ErrNotFound)
+				}
+				if c.Verify(id, "1234", true) {
+					t.Error("the reload revived a spent captcha")
+				}
+			}
+		})
+	}
+}
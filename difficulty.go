@@ -0,0 +1,172 @@
+package base64Captcha
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Risk describes how likely the client asking for a captcha is a bot.
+type Risk struct {
+	// Score is the risk score passed with ContextWithRiskScore, zero when
+	// the caller did not supply one.
+	Score float64
+	// Failures counts the recent failed verifications of the client, as
+	// tracked by the FailureTracker of the captcha.
+	Failures int
+}
+
+// DifficultyPolicy picks the driver of a captcha from the risk of the
+// client, see TieredDifficulty.
+type DifficultyPolicy interface {
+	// Driver returns the driver to generate a captcha with, or nil for the
+	// driver of the captcha.
+	Driver(risk Risk) Driver
+}
+
+// DifficultyTier is a driver used from a level of risk upwards.
+type DifficultyTier struct {
+	// MinScore and MinFailures are the thresholds of the tier, it is used
+	// when the risk reaches either of them. A zero threshold is not used.
+	MinScore    float64
+	MinFailures int
+	Driver      Driver
+}
+
+// TieredDifficulty is a DifficultyPolicy made of tiers ordered from the
+// easiest to the hardest. It picks the driver of the hardest tier reached
+// by the risk, and the first tier when none is reached:
+//
+//	TieredDifficulty{
+//		{Driver: NewDriverDigit(80, 240, 4, 0.3, 20)},
+//		{MinScore: 0.7, MinFailures: 3, Driver: NewDriverString(80, 240, 80,
+//			OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, 7, TxtAlphabet+TxtNumbers, nil, nil, nil)},
+//	}
+type TieredDifficulty []DifficultyTier
+
+// Driver implements DifficultyPolicy.
+func (t TieredDifficulty) Driver(risk Risk) Driver {
+	if len(t) == 0 {
+		return nil
+	}
+	d := t[0].Driver
+	for _, tier := range t[1:] {
+		if (tier.MinScore > 0 && risk.Score >= tier.MinScore) || (tier.MinFailures > 0 && risk.Failures >= tier.MinFailures) {
+			d = tier.Driver
+		}
+	}
+	return d
+}
+
+// riskScoreKey is the context key of the risk score.
+type riskScoreKey struct{}
+
+// ContextWithRiskScore returns a context carrying the risk score of the
+// client, such as the score of a bot detection service, for the
+// DifficultyPolicy of a Captcha.
+func ContextWithRiskScore(ctx context.Context, score float64) context.Context {
+	return context.WithValue(ctx, riskScoreKey{}, score)
+}
+
+// riskScoreFrom returns the risk score carried by ctx.
+func riskScoreFrom(ctx context.Context) float64 {
+	score, _ := ctx.Value(riskScoreKey{}).(float64)
+	return score
+}
+
+// FailureTracker counts the failed verifications of every client, which
+// is identified by the key passed with ContextWithClientKey. The count of a
+// client is forgotten once it has not failed for the tracker window.
+type FailureTracker struct {
+	window time.Duration
+
+	mu        sync.Mutex
+	clients   map[string]*failureCount
+	nextSweep time.Time
+}
+
+// failureCount is the count of a client.
+type failureCount struct {
+	n    int
+	last time.Time
+}
+
+// NewFailureTracker creates a tracker forgetting the failures of a client
+// after window without failures.
+func NewFailureTracker(window time.Duration) *FailureTracker {
+	return &FailureTracker{window: window, clients: make(map[string]*failureCount)}
+}
+
+// Failures returns the recent failures of the client key.
+func (t *FailureTracker) Failures(key string) int {
+	return t.failures(key, time.Now())
+}
+
+func (t *FailureTracker) failures(key string, now time.Time) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if c, ok := t.clients[key]; ok && now.Sub(c.last) < t.window {
+		return c.n
+	}
+	return 0
+}
+
+// Fail counts a failure of the client key.
+func (t *FailureTracker) Fail(key string) {
+	t.fail(key, time.Now())
+}
+
+func (t *FailureTracker) fail(key string, now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.After(t.nextSweep) {
+		for k, c := range t.clients {
+			if now.Sub(c.last) >= t.window {
+				delete(t.clients, k)
+			}
+		}
+		t.nextSweep = now.Add(t.window)
+	}
+	c, ok := t.clients[key]
+	if !ok || now.Sub(c.last) >= t.window {
+		c = &failureCount{}
+		t.clients[key] = c
+	}
+	c.n++
+	c.last = now
+}
+
+// isFailure reports whether a verification outcome counts as a failure of
+// the client. Unknown and expired captchas do not, they are not guesses.
+func isFailure(err error) bool {
+	return errors.Is(err, ErrMismatch) ||
+		errors.Is(err, ErrTooManyAttempts) ||
+		errors.Is(err, ErrBindingMismatch)
+}
+
+// driver returns the driver to generate a captcha with for the client of
+// ctx, as picked by the difficulty policy.
+func (c *Captcha) driver(ctx context.Context) Driver {
+	if c.Difficulty == nil {
+		return c.Driver
+	}
+	risk := Risk{Score: riskScoreFrom(ctx)}
+	if key := clientKeyFrom(ctx); key != "" && c.Failures != nil {
+		risk.Failures = c.Failures.Failures(key)
+	}
+	if d := c.Difficulty.Driver(risk); d != nil {
+		return d
+	}
+	return c.Driver
+}
+
+// trackFailure counts a failed verification of the client of ctx.
+func (c *Captcha) trackFailure(ctx context.Context, err error) {
+	if c.Failures == nil || !isFailure(err) {
+		return
+	}
+	if key := clientKeyFrom(ctx); key != "" {
+		c.Failures.Fail(key)
+	}
+}
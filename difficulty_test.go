@@ -0,0 +1,113 @@
+package base64Captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTieredDifficulty_Driver(t *testing.T) {
+	easy := NewDriverDigit(80, 240, 4, 0.3, 20)
+	medium := NewDriverDigit(80, 240, 6, 0.7, 80)
+	hard := NewDriverString(80, 240, 80, OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, 7, TxtAlphabet+TxtNumbers, nil, nil, nil)
+	policy := TieredDifficulty{
+		{Driver: easy},
+		{MinScore: 0.5, MinFailures: 2, Driver: medium},
+		{MinScore: 0.9, MinFailures: 5, Driver: hard},
+	}
+	tests := []struct {
+		name string
+		risk Risk
+		want Driver
+	}{
+		{"no risk", Risk{}, easy},
+		{"medium score", Risk{Score: 0.6}, medium},
+		{"medium failures", Risk{Failures: 2}, medium},
+		{"high score", Risk{Score: 0.95, Failures: 1}, hard},
+		{"high failures", Risk{Failures: 9}, hard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := policy.Driver(tt.risk); got != tt.want {
+				t.Errorf("Driver(%+v) = %T %p, want %T %p", tt.risk, got, got, tt.want, tt.want)
+			}
+		})
+	}
+	failuresOnly := TieredDifficulty{{Driver: easy}, {MinFailures: 3, Driver: hard}}
+	if got := failuresOnly.Driver(Risk{}); got != easy {
+		t.Errorf("Driver() of a failures-only tier without failures = %T %p, want %T %p", got, got, easy, easy)
+	}
+	if got := failuresOnly.Driver(Risk{Failures: 3}); got != hard {
+		t.Errorf("Driver() of a failures-only tier = %T %p, want %T %p", got, got, hard, hard)
+	}
+	if got := (TieredDifficulty{}).Driver(Risk{Score: 1}); got != nil {
+		t.Errorf("Driver() of no tiers = %v, want nil", got)
+	}
+}
+
+func TestFailureTracker(t *testing.T) {
+	tr := NewFailureTracker(time.Minute)
+	now := time.Now()
+	tr.fail("a", now)
+	tr.fail("a", now.Add(time.Second))
+	tr.fail("b", now)
+	if got := tr.failures("a", now.Add(2*time.Second)); got != 2 {
+		t.Errorf("failures(a) = %d, want 2", got)
+	}
+	if got := tr.failures("c", now); got != 0 {
+		t.Errorf("failures(c) = %d, want 0", got)
+	}
+	if got := tr.failures("a", now.Add(2*time.Minute)); got != 0 {
+		t.Errorf("failures(a) after the window = %d, want 0", got)
+	}
+	tr.fail("a", now.Add(2*time.Minute))
+	if got := tr.failures("a", now.Add(2*time.Minute)); got != 1 {
+		t.Errorf("failures(a) after a new failure = %d, want 1", got)
+	}
+	if _, ok := tr.clients["b"]; ok {
+		t.Error("the idle client was not swept")
+	}
+}
+
+func TestCaptcha_Difficulty(t *testing.T) {
+	easy := NewDriverDigit(80, 240, 4, 0.3, 20)
+	hard := NewDriverDigit(80, 240, 8, 0.7, 80)
+	c := NewCaptcha(easy, NewMemoryStore(10, Expiration))
+	c.Difficulty = TieredDifficulty{{Driver: easy}, {MinScore: 0.8, MinFailures: 2, Driver: hard}}
+	c.Failures = NewFailureTracker(time.Minute)
+	ctx := ContextWithClientKey(context.Background(), "10.0.0.1")
+
+	answerLen := func(ctx context.Context) int {
+		t.Helper()
+		_, _, answer, err := c.GenerateContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(answer)
+	}
+	if got := answerLen(ctx); got != 4 {
+		t.Errorf("answer length at no risk = %d, want 4", got)
+	}
+	if got := answerLen(ContextWithRiskScore(ctx, 0.9)); got != 8 {
+		t.Errorf("answer length at a high risk score = %d, want 8", got)
+	}
+
+	for i := 0; i < 2; i++ {
+		id, _, _, err := c.GenerateContext(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.CheckContext(ctx, id, "wrong", true)
+	}
+	// Unknown captchas are not counted.
+	c.CheckContext(ctx, "unknown", "wrong", true)
+	if got := c.Failures.Failures("10.0.0.1"); got != 2 {
+		t.Errorf("Failures() = %d, want 2", got)
+	}
+	if got := answerLen(ctx); got != 8 {
+		t.Errorf("answer length after failures = %d, want 8", got)
+	}
+	if got := answerLen(ContextWithClientKey(context.Background(), "10.0.0.2")); got != 4 {
+		t.Errorf("answer length of another client = %d, want 4", got)
+	}
+}
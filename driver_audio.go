@@ -4,12 +4,17 @@
 
 package base64Captcha
 
+import "fmt"
+
 // DriverAudio captcha config for captcha-engine-audio.
 type DriverAudio struct {
 	// Length Default number of digits in captcha solution.
 	Length int
 	// Language possible values for lang are "en", "ja", "ru", "zh".
 	Language string
+	// Rand source of randomness (optional), a ChaCha8 stream seeded from
+	// crypto/rand by default.
+	Rand RandSource
 }
 
 // DefaultDriverAudio is a default audio driver
@@ -20,10 +25,24 @@ func NewDriverAudio(length int, language string) *DriverAudio {
 	return &DriverAudio{Length: length, Language: language}
 }
 
+// Validate checks the length and the language of the driver.
+func (d *DriverAudio) Validate() error {
+	if err := validateLength(d.Length); err != nil {
+		return err
+	}
+	if _, ok := digitSounds[d.Language]; !ok {
+		return fmt.Errorf("%w: no sounds for language %q", ErrInvalidConfig, d.Language)
+	}
+	return nil
+}
+
 // DrawCaptcha creates audio captcha item
 func (d *DriverAudio) DrawCaptcha(content string) (item Item, err error) {
-	digits := stringToFakeByte(content)
-	audio, err := newAudio("", digits, d.Language)
+	digits, err := parseDigits(content)
+	if err != nil {
+		return nil, err
+	}
+	audio, err := newAudio(newRandGen(d.Rand), "", digits, d.Language)
 	if err != nil {
 		return nil, err
 	}
@@ -32,8 +51,13 @@ func (d *DriverAudio) DrawCaptcha(content string) (item Item, err error) {
 
 // GenerateIdQuestionAnswer creates id,captcha content and answer
 func (d *DriverAudio) GenerateIdQuestionAnswer() (id, q, a string, _ error) {
-	id = RandomId()
-	digits := randomDigits(d.Length)
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
+}
+
+// GenerateSpecificIdQuestionAnswer creates captcha content and answer for the given id
+func (d *DriverAudio) GenerateSpecificIdQuestionAnswer(mId string) (id, q, a string, _ error) {
+	id = mId
+	digits := newRandGen(d.Rand).digits(d.Length)
 	a = parseDigitsToString(digits)
 	return id, a, a, nil
 }
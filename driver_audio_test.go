@@ -5,6 +5,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -91,3 +92,17 @@ func TestDriverAudio_GenerateIdQuestionAnswer(t *testing.T) {
 		})
 	}
 }
+
+func TestDriverAudio_Validate(t *testing.T) {
+	if err := DefaultDriverAudio.Validate(); err != nil {
+		t.Errorf("Validate() of the default driver error = %v", err)
+	}
+	for _, d := range []*DriverAudio{NewDriverAudio(0, "en"), NewDriverAudio(4, "xx")} {
+		if err := d.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Validate() of %+v error = %v, want ErrInvalidConfig", d, err)
+		}
+	}
+	if _, err := DefaultDriverAudio.DrawCaptcha("1x3"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("DrawCaptcha() error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -1,9 +1,8 @@
 package base64Captcha
 
 import (
-	"crypto/rand"
+	"fmt"
 	"image/color"
-	"math/big"
 	"strings"
 
 	"github.com/golang/freetype/truetype"
@@ -37,6 +36,9 @@ type DriverChinese struct {
 	//Fonts loads by name see fonts.go's comment
 	Fonts      []string
 	fontsArray []*truetype.Font
+
+	//Rand source of randomness (optional), a ChaCha8 stream seeded from crypto/rand by default
+	Rand RandSource
 }
 
 // NewDriverChinese creates a driver of Chinese characters
@@ -58,53 +60,80 @@ func NewDriverChinese(height int, width int, noiseCount int, showLineOptions int
 	return &DriverChinese{Height: height, Width: width, NoiseCount: noiseCount, ShowLineOptions: showLineOptions, Length: length, Source: source, BgColor: bgColor, fontsStorage: fontsStorage, fontsArray: tfs}
 }
 
-// ConvertFonts loads fonts by names
+// ConvertFonts loads fonts by names, it panics if a font cannot be loaded.
 func (d *DriverChinese) ConvertFonts() *DriverChinese {
+	if err := d.LoadFonts(); err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// LoadFonts loads fonts by names, or returns an error if a font cannot be
+// loaded.
+func (d *DriverChinese) LoadFonts() error {
 	if d.fontsStorage == nil {
 		d.fontsStorage = DefaultEmbeddedFonts
 	}
+	tfs, err := loadFonts(d.fontsStorage, d.Fonts)
+	if err != nil {
+		return err
+	}
+	d.fontsArray = tfs
+	return nil
+}
 
-	tfs := []*truetype.Font{}
-	for _, fff := range d.Fonts {
-		tf := d.fontsStorage.LoadFontByName("fonts/" + fff)
-		tfs = append(tfs, tf)
+// Validate checks the image size, the length and the source of the driver.
+func (d *DriverChinese) Validate() error {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return err
 	}
-	if len(tfs) == 0 {
-		tfs = fontsAll
+	if err := d.validateText(); err != nil {
+		return err
 	}
-	d.fontsArray = tfs
+	if d.NoiseCount < 0 {
+		return fmt.Errorf("%w: noise count %d must not be negative", ErrInvalidConfig, d.NoiseCount)
+	}
+	return nil
+}
 
-	return d
+// validateText checks the length and the source of the driver.
+func (d *DriverChinese) validateText() error {
+	if err := validateLength(d.Length); err != nil {
+		return err
+	}
+	if d.Source == "" {
+		return fmt.Errorf("%w: source must not be empty", ErrInvalidConfig)
+	}
+	return nil
 }
 
 // GenerateIdQuestionAnswer generates captcha content and its answer
 func (d *DriverChinese) GenerateIdQuestionAnswer() (id, content, answer string, _ error) {
-	id = RandomId()
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
+}
+
+// GenerateSpecificIdQuestionAnswer generates captcha content and its answer for the given id
+func (d *DriverChinese) GenerateSpecificIdQuestionAnswer(mId string) (id, content, answer string, _ error) {
+	if err := d.validateText(); err != nil {
+		return "", "", "", err
+	}
+	id = mId
+	r := newRandGen(d.Rand)
 
 	ss := strings.Split(d.Source, ",")
 	length := len(ss)
 	if length == 1 {
-		c, err := RandText(d.Length, ss[0])
-		if err != nil {
-			return "", "", "", err
-		}
+		c := r.text(d.Length, ss[0])
 		return id, c, c, nil
 	}
 	if length <= d.Length {
-		c, err := RandText(d.Length, TxtNumbers+TxtAlphabet)
-		if err != nil {
-			return "", "", "", err
-		}
+		c := r.text(d.Length, TxtNumbers+TxtAlphabet)
 		return id, c, c, nil
 	}
 
 	res := make([]string, d.Length)
 	for k := range res {
-		n, err := rand.Int(rand.Reader, big.NewInt(int64(length)))
-		if err != nil {
-			return "", "", "", err
-		}
-		res[k] = ss[int(n.Int64())]
+		res[k] = ss[r.IntN(length)]
 	}
 
 	content = strings.Join(res, "")
@@ -113,18 +142,21 @@ func (d *DriverChinese) GenerateIdQuestionAnswer() (id, content, answer string,
 
 // DrawCaptcha generates captcha item(image)
 func (d *DriverChinese) DrawCaptcha(content string) (item Item, _ error) {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return nil, err
+	}
+	if err := validateLength(d.Length); err != nil {
+		return nil, err
+	}
+	r := newRandGen(d.Rand)
 
 	var bgc color.RGBA
 	if d.BgColor != nil {
 		bgc = *d.BgColor
 	} else {
-		var err error
-		bgc, err = RandLightColor()
-		if err != nil {
-			return nil, err
-		}
+		bgc = r.lightColor()
 	}
-	itemChar := NewItemChar(d.Width, d.Height, bgc)
+	itemChar := newItemChar(r, d.Width, d.Height, bgc)
 
 	//draw hollow line
 	if d.ShowLineOptions&OptionShowHollowLine == OptionShowHollowLine {
@@ -144,13 +176,10 @@ func (d *DriverChinese) DrawCaptcha(content string) (item Item, _ error) {
 	//draw noise
 	if d.NoiseCount > 0 {
 		source := TxtNumbers + TxtAlphabet + ",.[]<>"
-		noise, err := RandText(d.NoiseCount, strings.Repeat(source, d.NoiseCount))
-		if err != nil {
-			return
-		}
-		err = itemChar.drawNoise(noise, d.fontsArray)
+		noise := r.text(d.NoiseCount, strings.Repeat(source, d.NoiseCount))
+		err := itemChar.drawNoise(noise, d.fontsArray)
 		if err != nil {
-			return
+			return nil, err
 		}
 	}
 
@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"image/color"
 	"reflect"
 	"testing"
@@ -106,3 +107,30 @@ func TestDriverChinese_DrawCaptcha(t *testing.T) {
 		})
 	}
 }
+
+func TestDriverChinese_Validate(t *testing.T) {
+	if err := NewDriverChinese(60, 240, 10, 0, 2, TxtChineseCharaters, nil, nil, nil).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, d := range []*DriverChinese{
+		NewDriverChinese(60, 0, 10, 0, 2, TxtChineseCharaters, nil, nil, nil),
+		NewDriverChinese(60, 240, 10, 0, 0, TxtChineseCharaters, nil, nil, nil),
+		NewDriverChinese(60, 240, 10, 0, 2, "", nil, nil, nil),
+	} {
+		if err := d.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Validate() of %+v error = %v, want ErrInvalidConfig", d, err)
+		}
+	}
+	for _, d := range []*DriverChinese{
+		NewDriverChinese(60, 240, 10, 0, 0, TxtChineseCharaters, nil, nil, nil),
+		{Width: 240, Height: 60, Length: 2},
+	} {
+		if _, _, _, err := d.GenerateIdQuestionAnswer(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("GenerateIdQuestionAnswer() of %+v error = %v, want ErrInvalidConfig", d, err)
+		}
+	}
+	d := NewDriverChinese(60, 240, 10, 0, 0, TxtChineseCharaters, nil, nil, nil)
+	if item, err := d.DrawCaptcha("abcd"); item != nil || !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("DrawCaptcha() without length = %v, %v, want ErrInvalidConfig", item, err)
+	}
+}
@@ -14,7 +14,7 @@
 
 package base64Captcha
 
-import "math/rand/v2"
+import "fmt"
 
 // DriverDigit config for captcha-engine-digit.
 type DriverDigit struct {
@@ -28,6 +28,9 @@ type DriverDigit struct {
 	MaxSkew float64
 	// DotCount Number of background circles.
 	DotCount int
+	// Rand source of randomness (optional), a ChaCha8 stream seeded from
+	// crypto/rand by default.
+	Rand RandSource
 }
 
 // NewDriverDigit creates a driver of digit
@@ -38,44 +41,61 @@ func NewDriverDigit(height int, width int, length int, maxSkew float64, dotCount
 // DefaultDriverDigit is a default driver of digit
 var DefaultDriverDigit = NewDriverDigit(80, 240, 5, 0.7, 80)
 
+// Validate checks that the digits fit in the image.
+func (d *DriverDigit) Validate() error {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return err
+	}
+	if err := validateLength(d.Length); err != nil {
+		return err
+	}
+	if d.DotCount < 1 {
+		return fmt.Errorf("%w: dot count %d must be at least 1", ErrInvalidConfig, d.DotCount)
+	}
+	if d.MaxSkew < 0 {
+		return fmt.Errorf("%w: max skew %v must not be negative", ErrInvalidConfig, d.MaxSkew)
+	}
+	_, _, _, err := new(ItemDigit).layout(d.Width, d.Height, d.Length)
+	return err
+}
+
 // GenerateIdQuestionAnswer creates captcha content and answer
 func (d *DriverDigit) GenerateIdQuestionAnswer() (id, q, a string, err error) {
-	id = RandomId()
-	digits := randomDigits(d.Length)
-	a = parseDigitsToString(digits)
-	return id, a, a, nil
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
 }
 
-// GenerateIdQuestionAnswer creates captcha content and answer
-func (d *DriverDigit) GenerateSpecificIdQuestionAnswer(mId string) (id, q, a string) {
+// GenerateSpecificIdQuestionAnswer creates captcha content and answer for the given id
+func (d *DriverDigit) GenerateSpecificIdQuestionAnswer(mId string) (id, q, a string, err error) {
 	id = mId
-	digits := randomDigits(d.Length)
+	digits := newRandGen(d.Rand).digits(d.Length)
 	a = parseDigitsToString(digits)
-	return id, a, a
+	return id, a, a, nil
 }
 
 // DrawCaptcha creates digit captcha item
 func (d *DriverDigit) DrawCaptcha(content string) (item Item, err error) {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return nil, err
+	}
 	// Initialize PRNG.
-	itemDigit, err := NewItemDigit(d.Width, d.Height, d.DotCount, d.MaxSkew)
+	r := newRandGen(d.Rand)
+	itemDigit, err := newItemDigit(r, d.Width, d.Height, d.DotCount, d.MaxSkew)
 	if err != nil {
 		return nil, err
 	}
 	//parse digits to string
-	digits := stringToFakeByte(content)
+	digits, err := parseDigits(content)
+	if err != nil {
+		return nil, err
+	}
 
-	itemDigit.calculateSizes(d.Width, d.Height, len(digits))
 	// Randomly position captcha inside the image.
-	maxx := d.Width - (itemDigit.width+itemDigit.dotSize)*len(digits) - itemDigit.dotSize
-	maxy := d.Height - itemDigit.height - itemDigit.dotSize*2
-	var border int
-	if d.Width > d.Height {
-		border = d.Height / 5
-	} else {
-		border = d.Width / 5
+	xRange, yRange, border, err := itemDigit.layout(d.Width, d.Height, len(digits))
+	if err != nil {
+		return nil, err
 	}
-	x := rand.IntN(maxx-border*2) + border
-	y := rand.IntN(maxy-border*2) + border
+	x := r.IntN(xRange) + border
+	y := r.IntN(yRange) + border
 	// Draw digits.
 	for _, n := range digits {
 		itemDigit.drawDigit(digitFontData[n], x, y)
@@ -84,7 +104,7 @@ func (d *DriverDigit) DrawCaptcha(content string) (item Item, err error) {
 	// Draw strike-through line.
 	itemDigit.strikeThrough()
 	// Apply wave distortion.
-	itemDigit.distort(rand.Float64()*(10-5)+5, rand.Float64()*(200-100)+100)
+	itemDigit.distort(r.float64Range(5, 10), r.float64Range(100, 200))
 	// Fill image with random circles.
 	itemDigit.fillWithCircles(d.DotCount, itemDigit.dotSize)
 	return itemDigit, nil
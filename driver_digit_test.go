@@ -15,6 +15,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"reflect"
 	"testing"
 )
@@ -116,3 +117,50 @@ func TestDriverDigit_GenerateIdQuestionAnswer(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkDriverDigit_DrawCaptcha(b *testing.B) {
+	benchmarkDrawCaptcha(b, func(src RandSource) Driver {
+		d := NewDriverDigit(80, 240, 5, 0.7, 80)
+		d.Rand = src
+		return d
+	})
+}
+
+func TestDriverDigit_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       *DriverDigit
+		wantErr bool
+	}{
+		{"default", DefaultDriverDigit, false},
+		{"zero size", &DriverDigit{Length: 5, MaxSkew: 0.7, DotCount: 80}, true},
+		{"too small for digits", NewDriverDigit(12, 30, 5, 0.7, 80), true},
+		{"no digits", NewDriverDigit(80, 240, 0, 0.7, 80), true},
+		{"no dots", NewDriverDigit(80, 240, 5, 0.7, 0), true},
+		{"negative skew", NewDriverDigit(80, 240, 5, -1, 80), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.d.Validate()
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidConfig)) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDriverDigit_DrawCaptcha_invalid(t *testing.T) {
+	for _, tt := range []struct {
+		d       *DriverDigit
+		content string
+	}{
+		{NewDriverDigit(0, 0, 5, 0.7, 80), "12345"},
+		{NewDriverDigit(12, 30, 5, 0.7, 80), "12345"},
+		{DefaultDriverDigit, "12a45"},
+		{DefaultDriverDigit, ""},
+	} {
+		if _, err := tt.d.DrawCaptcha(tt.content); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("DrawCaptcha(%q) of %dx%d error = %v, want ErrInvalidConfig", tt.content, tt.d.Width, tt.d.Height, err)
+		}
+	}
+}
@@ -1,10 +1,9 @@
 package base64Captcha
 
 import (
-	"crypto/rand"
+	"fmt"
 	"image/color"
 	"log"
-	"math/big"
 
 	"github.com/golang/freetype/truetype"
 )
@@ -25,6 +24,11 @@ var langMap = map[string][]int{
 }
 
 func generateRandomRune(size int, code string) string {
+	return newRandGen(nil).runes(size, code)
+}
+
+// runes generates size random runes of the language code.
+func (r randGen) runes(size int, code string) string {
 	lang, ok := langMap[code]
 	if !ok {
 		log.Printf("can not font language of %s \n", code)
@@ -34,13 +38,7 @@ func generateRandomRune(size int, code string) string {
 	end := lang[1]
 	randRune := make([]rune, size)
 	for i := range randRune {
-		n, err := rand.Int(rand.Reader, big.NewInt(int64(end-start)))
-		if err != nil {
-			log.Println("generate random rune error:", err)
-			n = big.NewInt(0)
-		}
-		idx := int(n.Int64()) + start
-		randRune[i] = rune(idx)
+		randRune[i] = rune(r.intN(end-start) + start)
 	}
 	return string(randRune)
 }
@@ -70,6 +68,9 @@ type DriverLanguage struct {
 	//Fonts loads by name see fonts.go's comment
 	Fonts        []*truetype.Font
 	LanguageCode string
+
+	//Rand source of randomness (optional), a ChaCha8 stream seeded from crypto/rand by default
+	Rand RandSource
 }
 
 // NewDriverLanguage creates a driver
@@ -77,26 +78,48 @@ func NewDriverLanguage(height int, width int, noiseCount int, showLineOptions in
 	return &DriverLanguage{Height: height, Width: width, NoiseCount: noiseCount, ShowLineOptions: showLineOptions, Length: length, BgColor: bgColor, fontsStorage: fontsStorage, Fonts: fonts, LanguageCode: languageCode}
 }
 
+// Validate checks the image size, the length and the language of the driver.
+func (d *DriverLanguage) Validate() error {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return err
+	}
+	if err := validateLength(d.Length); err != nil {
+		return err
+	}
+	if _, ok := langMap[d.LanguageCode]; !ok {
+		return fmt.Errorf("%w: unknown language code %q", ErrInvalidConfig, d.LanguageCode)
+	}
+	if d.NoiseCount < 0 {
+		return fmt.Errorf("%w: noise count %d must not be negative", ErrInvalidConfig, d.NoiseCount)
+	}
+	return nil
+}
+
 // GenerateIdQuestionAnswer creates content and answer
-func (d *DriverLanguage) GenerateIdQuestionAnswer() (id, content, answer string) {
-	id = RandomId()
-	content = generateRandomRune(d.Length, d.LanguageCode)
-	return id, content, content
+func (d *DriverLanguage) GenerateIdQuestionAnswer() (id, content, answer string, _ error) {
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
+}
+
+// GenerateSpecificIdQuestionAnswer creates content and answer for the given id
+func (d *DriverLanguage) GenerateSpecificIdQuestionAnswer(mId string) (id, content, answer string, _ error) {
+	id = mId
+	content = newRandGen(d.Rand).runes(d.Length, d.LanguageCode)
+	return id, content, content, nil
 }
 
 // DrawCaptcha creates item
 func (d *DriverLanguage) DrawCaptcha(content string) (item Item, _ error) {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return nil, err
+	}
+	r := newRandGen(d.Rand)
 	var bgc color.RGBA
 	if d.BgColor != nil {
 		bgc = *d.BgColor
 	} else {
-		var err error
-		bgc, err = RandLightColor()
-		if err != nil {
-			return nil, err
-		}
+		bgc = r.lightColor()
 	}
-	itemChar := NewItemChar(d.Width, d.Height, bgc)
+	itemChar := newItemChar(r, d.Width, d.Height, bgc)
 
 	//draw hollow line
 	if d.ShowLineOptions&OptionShowHollowLine == OptionShowHollowLine {
@@ -115,8 +138,8 @@ func (d *DriverLanguage) DrawCaptcha(content string) (item Item, _ error) {
 
 	//draw noise
 	if d.NoiseCount > 0 {
-		noise, err := RandText(d.NoiseCount, TxtNumbers+TxtAlphabet+",.[]<>")
-		err = itemChar.drawNoise(noise, fontsAll)
+		noise := r.text(d.NoiseCount, TxtNumbers+TxtAlphabet+",.[]<>")
+		err := itemChar.drawNoise(noise, fontsAll)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"image/color"
 	"reflect"
 	"testing"
@@ -12,7 +13,7 @@ func TestDriverLanguage_DrawCaptcha(t *testing.T) {
 	ds := NewDriverLanguage(80, 240, 5, OptionShowSineLine|OptionShowSlimeLine|OptionShowHollowLine, 5, nil, nil, []*truetype.Font{fontChinese}, "emotion")
 
 	for i := 0; i < 40; i++ {
-		_, q, _ := ds.GenerateIdQuestionAnswer()
+		_, q, _, _ := ds.GenerateIdQuestionAnswer()
 		item, err := ds.DrawCaptcha(q)
 		if err != nil {
 			t.Error(err)
@@ -81,7 +82,11 @@ func TestDriverLanguage_GenerateIdQuestionAnswer(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotId, gotContent, gotAnswer := tt.d.GenerateIdQuestionAnswer()
+			gotId, gotContent, gotAnswer, err := tt.d.GenerateIdQuestionAnswer()
+			if err != nil {
+				t.Errorf("DriverLanguage.GenerateIdQuestionAnswer() error = %v", err)
+				return
+			}
 			if gotId != tt.wantId {
 				t.Errorf("DriverLanguage.GenerateIdQuestionAnswer() gotId = %v, want %v", gotId, tt.wantId)
 			}
@@ -94,3 +99,18 @@ func TestDriverLanguage_GenerateIdQuestionAnswer(t *testing.T) {
 		})
 	}
 }
+
+func TestDriverLanguage_Validate(t *testing.T) {
+	if err := NewDriverLanguage(60, 240, 10, 0, 4, nil, nil, nil, "greek").Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, d := range []*DriverLanguage{
+		NewDriverLanguage(0, 240, 10, 0, 4, nil, nil, nil, "greek"),
+		NewDriverLanguage(60, 240, 10, 0, 0, nil, nil, nil, "greek"),
+		NewDriverLanguage(60, 240, 10, 0, 4, nil, nil, nil, "emotion"),
+	} {
+		if err := d.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Validate() of %+v error = %v, want ErrInvalidConfig", d, err)
+		}
+	}
+}
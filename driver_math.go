@@ -1,10 +1,8 @@
 package base64Captcha
 
 import (
-	"crypto/rand"
 	"fmt"
 	"image/color"
-	"math/big"
 	"strings"
 
 	"github.com/golang/freetype/truetype"
@@ -33,6 +31,9 @@ type DriverMath struct {
 	//Fonts loads by name see fonts.go's comment
 	Fonts      []string
 	fontsArray []*truetype.Font
+
+	//Rand source of randomness (optional), a ChaCha8 stream seeded from crypto/rand by default
+	Rand RandSource
 }
 
 // NewDriverMath creates a driver of math
@@ -54,79 +55,67 @@ func NewDriverMath(height int, width int, noiseCount int, showLineOptions int, b
 	return &DriverMath{Height: height, Width: width, NoiseCount: noiseCount, ShowLineOptions: showLineOptions, fontsArray: tfs, BgColor: bgColor, Fonts: fonts}
 }
 
-// ConvertFonts loads fonts from names
+// ConvertFonts loads fonts by names, it panics if a font cannot be loaded.
 func (d *DriverMath) ConvertFonts() *DriverMath {
-	if d.fontsStorage == nil {
-		d.fontsStorage = DefaultEmbeddedFonts
+	if err := d.LoadFonts(); err != nil {
+		panic(err)
 	}
+	return d
+}
 
-	tfs := []*truetype.Font{}
-	for _, fff := range d.Fonts {
-		tf := d.fontsStorage.LoadFontByName("fonts/" + fff)
-		tfs = append(tfs, tf)
+// LoadFonts loads fonts by names, or returns an error if a font cannot be
+// loaded.
+func (d *DriverMath) LoadFonts() error {
+	if d.fontsStorage == nil {
+		d.fontsStorage = DefaultEmbeddedFonts
 	}
-	if len(tfs) == 0 {
-		tfs = fontsAll
+	tfs, err := loadFonts(d.fontsStorage, d.Fonts)
+	if err != nil {
+		return err
 	}
 	d.fontsArray = tfs
+	return nil
+}
 
-	return d
+// Validate checks the image size and the noise count of the driver.
+func (d *DriverMath) Validate() error {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return err
+	}
+	if d.NoiseCount < 0 {
+		return fmt.Errorf("%w: noise count %d must not be negative", ErrInvalidConfig, d.NoiseCount)
+	}
+	return nil
 }
 
 // GenerateIdQuestionAnswer creates id,captcha content and answer
 func (d *DriverMath) GenerateIdQuestionAnswer() (id, question, answer string, _ error) {
-	id = RandomId()
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
+}
+
+// GenerateSpecificIdQuestionAnswer creates captcha content and answer for the given id
+func (d *DriverMath) GenerateSpecificIdQuestionAnswer(mId string) (id, question, answer string, _ error) {
+	id = mId
+	r := newRandGen(d.Rand)
 	operators := []string{"+", "-", "x"}
 	var mathResult int32
-	n, err := rand.Int(rand.Reader, big.NewInt(3))
-	if err != nil {
-		return "", "", "", err
-	}
-	switch operators[int(n.Int64())] {
+	switch operators[r.IntN(3)] {
 	case "+":
-		aN, err := rand.Int(rand.Reader, big.NewInt(20))
-		if err != nil {
-			return "", "", "", err
-		}
-		a := int32(aN.Int64())
-		bN, err := rand.Int(rand.Reader, big.NewInt(20))
-		if err != nil {
-			return "", "", "", err
-		}
-		b := int32(bN.Int64())
+		a := int32(r.IntN(20))
+		b := int32(r.IntN(20))
 		question = fmt.Sprintf("%d+%d=?", a, b)
 		mathResult = a + b
 	case "x":
-		aN, err := rand.Int(rand.Reader, big.NewInt(10))
-		if err != nil {
-			return "", "", "", err
-		}
-		a := int32(aN.Int64())
-		bN, err := rand.Int(rand.Reader, big.NewInt(10))
-		if err != nil {
-			return "", "", "", err
-		}
-		b := int32(bN.Int64())
+		a := int32(r.IntN(10))
+		b := int32(r.IntN(10))
 		question = fmt.Sprintf("%dx%d=?", a, b)
 		mathResult = a * b
 	default:
-		aNFirst, err := rand.Int(rand.Reader, big.NewInt(100))
-		if err != nil {
-			return "", "", "", err
-		}
-		aFirst := int32(aNFirst.Int64())
-		aNSecond, err := rand.Int(rand.Reader, big.NewInt(20))
-		if err != nil {
-			return "", "", "", err
-		}
-		aSecond := int32(aNSecond.Int64())
+		aFirst := int32(r.IntN(100))
+		aSecond := int32(r.IntN(20))
 		a := aFirst + aSecond
 
-		bN, err := rand.Int(rand.Reader, big.NewInt(int64(a)))
-		if err != nil {
-			return "", "", "", err
-		}
-		b := int32(bN.Int64())
+		b := int32(r.intN(int(a)))
 		question = fmt.Sprintf("%d-%d=?", a, b)
 		mathResult = a - b
 
@@ -137,17 +126,17 @@ func (d *DriverMath) GenerateIdQuestionAnswer() (id, question, answer string, _
 
 // DrawCaptcha creates math captcha item
 func (d *DriverMath) DrawCaptcha(question string) (item Item, _ error) {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return nil, err
+	}
+	r := newRandGen(d.Rand)
 	var bgc color.RGBA
 	if d.BgColor != nil {
 		bgc = *d.BgColor
 	} else {
-		var err error
-		bgc, err = RandLightColor()
-		if err != nil {
-			return nil, err
-		}
+		bgc = r.lightColor()
 	}
-	itemChar := NewItemChar(d.Width, d.Height, bgc)
+	itemChar := newItemChar(r, d.Width, d.Height, bgc)
 
 	//波浪线 比较丑
 	if d.ShowLineOptions&OptionShowHollowLine == OptionShowHollowLine {
@@ -156,11 +145,8 @@ func (d *DriverMath) DrawCaptcha(question string) (item Item, _ error) {
 
 	//背景有文字干扰
 	if d.NoiseCount > 0 {
-		noise, err := RandText(d.NoiseCount, strings.Repeat(TxtNumbers, d.NoiseCount))
-		if err != nil {
-			return nil, err
-		}
-		err = itemChar.drawNoise(noise, fontsAll)
+		noise := r.text(d.NoiseCount, strings.Repeat(TxtNumbers, d.NoiseCount))
+		err := itemChar.drawNoise(noise, fontsAll)
 		if err != nil {
 			return nil, err
 		}
@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"image/color"
 	"reflect"
 	"testing"
@@ -130,3 +131,25 @@ func TestDriverMath_GenerateIdQuestionAnswer(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkDriverMath_DrawCaptcha(b *testing.B) {
+	benchmarkDrawCaptcha(b, func(src RandSource) Driver {
+		d := NewDriverMath(80, 240, 100, OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, nil, nil, nil)
+		d.Rand = src
+		return d
+	})
+}
+
+func TestDriverMath_Validate(t *testing.T) {
+	if err := NewDriverMath(60, 240, 10, 0, nil, nil, nil).Validate(); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+	for _, d := range []*DriverMath{NewDriverMath(5, 240, 10, 0, nil, nil, nil), NewDriverMath(60, 240, -1, 0, nil, nil, nil)} {
+		if err := d.Validate(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Validate() of %dx%d error = %v, want ErrInvalidConfig", d.Width, d.Height, err)
+		}
+	}
+	if _, err := (&DriverMath{}).DrawCaptcha("1+1=?"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("DrawCaptcha() error = %v, want ErrInvalidConfig", err)
+	}
+}
@@ -0,0 +1,180 @@
+package base64Captcha
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"os"
+	"sort"
+	"sync"
+)
+
+// DriverFactory builds a fully initialized driver from its JSON config.
+type DriverFactory func(config json.RawMessage) (Driver, error)
+
+var (
+	driverFactoriesMu sync.RWMutex
+	driverFactories   = make(map[string]DriverFactory)
+)
+
+// RegisterDriver makes a driver type available to DriverFromConfig and
+// NewDriverByName under name. Like database/sql.Register, it panics if the
+// factory is nil or the name is already registered. The built-in drivers are
+// registered as "audio", "chinese", "digit", "language", "math" and "string".
+func RegisterDriver(name string, factory DriverFactory) {
+	driverFactoriesMu.Lock()
+	defer driverFactoriesMu.Unlock()
+	if factory == nil {
+		panic("captcha: RegisterDriver factory is nil")
+	}
+	if _, dup := driverFactories[name]; dup {
+		panic("captcha: RegisterDriver called twice for driver " + name)
+	}
+	driverFactories[name] = factory
+}
+
+// RegisteredDrivers returns the sorted names of the registered drivers.
+func RegisteredDrivers() []string {
+	driverFactoriesMu.RLock()
+	defer driverFactoriesMu.RUnlock()
+	names := make([]string, 0, len(driverFactories))
+	for name := range driverFactories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DriverFromConfig builds a driver from a JSON object holding the name of
+// the registered driver in "type", next to the fields of the driver:
+//
+//	{"type": "string", "height": 60, "width": 240, "length": 4, "fonts": ["wqy-microhei.ttc"]}
+//
+// Fields left out keep the defaults of the driver type, fonts are loaded
+// and the driver is validated, so it is ready to use.
+func DriverFromConfig(config json.RawMessage) (Driver, error) {
+	var head struct {
+		Type string `json:"type"`
+	}
+	if err := json.Unmarshal(config, &head); err != nil {
+		return nil, fmt.Errorf("captcha: decoding driver config: %w", err)
+	}
+	return NewDriverByName(head.Type, config)
+}
+
+// NewDriverByName builds a driver of the type registered under name from
+// its JSON config, which holds the fields of the driver. An empty config
+// builds a driver with the defaults of the type. Drivers implementing
+// Validator are validated.
+func NewDriverByName(name string, config json.RawMessage) (Driver, error) {
+	driverFactoriesMu.RLock()
+	factory, ok := driverFactories[name]
+	driverFactoriesMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, name)
+	}
+	if len(config) == 0 {
+		config = json.RawMessage("{}")
+	}
+	d, err := factory(config)
+	if err == nil {
+		if v, ok := d.(Validator); ok {
+			err = v.Validate()
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("captcha: %s driver config: %w", name, err)
+	}
+	return d, nil
+}
+
+// LoadDriversFromFile builds the drivers of a JSON file mapping names to
+// driver configs, as read by DriverFromConfig:
+//
+//	{
+//	  "login":  {"type": "digit", "length": 5},
+//	  "signup": {"type": "math", "noiseCount": 20}
+//	}
+func LoadDriversFromFile(path string) (map[string]Driver, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var configs map[string]json.RawMessage
+	if err := json.Unmarshal(b, &configs); err != nil {
+		return nil, fmt.Errorf("captcha: decoding %s: %w", path, err)
+	}
+	drivers := make(map[string]Driver, len(configs))
+	for name, config := range configs {
+		d, err := DriverFromConfig(config)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		drivers[name] = d
+	}
+	return drivers, nil
+}
+
+func init() {
+	RegisterDriver("audio", func(config json.RawMessage) (Driver, error) {
+		d := *DefaultDriverAudio
+		if err := json.Unmarshal(config, &d); err != nil {
+			return nil, err
+		}
+		return &d, nil
+	})
+	RegisterDriver("digit", func(config json.RawMessage) (Driver, error) {
+		d := *DefaultDriverDigit
+		if err := json.Unmarshal(config, &d); err != nil {
+			return nil, err
+		}
+		return &d, nil
+	})
+	RegisterDriver("string", func(config json.RawMessage) (Driver, error) {
+		d := DriverString{Height: 60, Width: 240, Length: 4, Source: TxtNumbers + TxtAlphabet}
+		if err := json.Unmarshal(config, &d); err != nil {
+			return nil, err
+		}
+		if err := d.LoadFonts(); err != nil {
+			return nil, err
+		}
+		return &d, nil
+	})
+	RegisterDriver("chinese", func(config json.RawMessage) (Driver, error) {
+		d := DriverChinese{Height: 60, Width: 240, Length: 2, Source: TxtChineseCharaters, Fonts: []string{"wqy-microhei.ttc"}}
+		if err := json.Unmarshal(config, &d); err != nil {
+			return nil, err
+		}
+		if err := d.LoadFonts(); err != nil {
+			return nil, err
+		}
+		return &d, nil
+	})
+	RegisterDriver("math", func(config json.RawMessage) (Driver, error) {
+		d := DriverMath{Height: 60, Width: 240}
+		if err := json.Unmarshal(config, &d); err != nil {
+			return nil, err
+		}
+		if err := d.LoadFonts(); err != nil {
+			return nil, err
+		}
+		return &d, nil
+	})
+	RegisterDriver("language", func(config json.RawMessage) (Driver, error) {
+		// The fonts of DriverLanguage are not names, the content is drawn
+		// with the Chinese font anyway.
+		c := struct {
+			Height          int
+			Width           int
+			NoiseCount      int
+			ShowLineOptions int
+			Length          int
+			BgColor         *color.RGBA
+			LanguageCode    string
+		}{Height: 60, Width: 240, Length: 4, LanguageCode: "latin"}
+		if err := json.Unmarshal(config, &c); err != nil {
+			return nil, err
+		}
+		return NewDriverLanguage(c.Height, c.Width, c.NoiseCount, c.ShowLineOptions, c.Length, c.BgColor, nil, nil, c.LanguageCode), nil
+	})
+}
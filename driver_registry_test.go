@@ -0,0 +1,161 @@
+package base64Captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRegisteredDrivers(t *testing.T) {
+	want := []string{"audio", "chinese", "digit", "language", "math", "string"}
+	if got := RegisteredDrivers(); !reflect.DeepEqual(got, want) {
+		t.Errorf("RegisteredDrivers() = %v, want %v", got, want)
+	}
+}
+
+func TestRegisterDriver_duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("RegisterDriver() of a registered name did not panic")
+		}
+	}()
+	RegisterDriver("digit", func(json.RawMessage) (Driver, error) { return DefaultDriverDigit, nil })
+}
+
+func TestDriverFromConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		check  func(t *testing.T, d Driver)
+	}{
+		{"digit defaults", `{"type": "digit", "length": 4}`, func(t *testing.T, d Driver) {
+			want := *DefaultDriverDigit
+			want.Length = 4
+			if got := *d.(*DriverDigit); got != want {
+				t.Errorf("driver = %+v, want %+v", got, want)
+			}
+		}},
+		{"audio", `{"type": "audio", "Language": "zh"}`, func(t *testing.T, d Driver) {
+			if got := d.(*DriverAudio); got.Language != "zh" || got.Length != DefaultDriverAudio.Length {
+				t.Errorf("driver = %+v", got)
+			}
+		}},
+		{"string fonts", `{"type": "string", "height": 50, "fonts": ["RitaSmith.ttf"]}`, func(t *testing.T, d Driver) {
+			got := d.(*DriverString)
+			if got.Height != 50 || got.Width != 240 || len(got.fontsArray) != 1 {
+				t.Errorf("driver = %+v", got)
+			}
+		}},
+		{"math", `{"type": "math", "noiseCount": 5, "bgColor": {"R": 1, "G": 2, "B": 3, "A": 255}}`, func(t *testing.T, d Driver) {
+			got := d.(*DriverMath)
+			if got.NoiseCount != 5 || got.BgColor == nil || got.BgColor.B != 3 || len(got.fontsArray) == 0 {
+				t.Errorf("driver = %+v", got)
+			}
+		}},
+		{"chinese", `{"type": "chinese"}`, func(t *testing.T, d Driver) {
+			if got := d.(*DriverChinese); len(got.fontsArray) != 1 {
+				t.Errorf("driver = %+v", got)
+			}
+		}},
+		{"language", `{"type": "language", "languageCode": "greek"}`, func(t *testing.T, d Driver) {
+			if got := d.(*DriverLanguage); got.LanguageCode != "greek" || got.Length != 4 {
+				t.Errorf("driver = %+v", got)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := DriverFromConfig(json.RawMessage(tt.config))
+			if err != nil {
+				t.Fatalf("DriverFromConfig() error = %v", err)
+			}
+			tt.check(t, d)
+			_, q, _, err := d.GenerateIdQuestionAnswer()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.DrawCaptcha(q); err != nil {
+				t.Errorf("DrawCaptcha() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestDriverFromConfig_errors(t *testing.T) {
+	if _, err := DriverFromConfig(json.RawMessage(`{"type": "nope"}`)); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("unknown type error = %v, want ErrUnknownDriver", err)
+	}
+	if _, err := DriverFromConfig(json.RawMessage(`[]`)); err == nil {
+		t.Error("non object config did not fail")
+	}
+	if _, err := DriverFromConfig(json.RawMessage(`{"type": "digit", "height": "tall"}`)); err == nil {
+		t.Error("bad field did not fail")
+	}
+}
+
+func TestLoadDriversFromFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "drivers.json")
+	config := `{"login": {"type": "digit"}, "signup": {"type": "math"}}`
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	drivers, err := LoadDriversFromFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := drivers["login"].(*DriverDigit); !ok {
+		t.Errorf("login = %T, want *DriverDigit", drivers["login"])
+	}
+	if _, ok := drivers["signup"].(*DriverMath); !ok {
+		t.Errorf("signup = %T, want *DriverMath", drivers["signup"])
+	}
+
+	if err := os.WriteFile(path, []byte(`{"login": {"type": "nope"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadDriversFromFile(path); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("LoadDriversFromFile() error = %v, want ErrUnknownDriver", err)
+	}
+	if _, err := LoadDriversFromFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("missing file did not fail")
+	}
+}
+
+func TestNewDriverByName(t *testing.T) {
+	d, err := NewDriverByName("digit", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := *d.(*DriverDigit); got != *DefaultDriverDigit {
+		t.Errorf("driver = %+v, want %+v", got, *DefaultDriverDigit)
+	}
+	d, err = NewDriverByName("string", json.RawMessage(`{"Length": 6}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := d.(*DriverString).Length; got != 6 {
+		t.Errorf("Length = %d, want 6", got)
+	}
+	if _, err := NewDriverByName("nope", nil); !errors.Is(err, ErrUnknownDriver) {
+		t.Errorf("NewDriverByName() error = %v, want ErrUnknownDriver", err)
+	}
+}
+
+func TestDriverFromConfig_invalid(t *testing.T) {
+	for _, config := range []string{
+		`{"type": "digit", "width": 5}`,
+		`{"type": "audio", "language": "xx"}`,
+		`{"type": "string", "source": ""}`,
+		`{"type": "language", "languageCode": "emotion"}`,
+	} {
+		if _, err := DriverFromConfig(json.RawMessage(config)); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("DriverFromConfig(%s) error = %v, want ErrInvalidConfig", config, err)
+		}
+	}
+	if _, err := DriverFromConfig(json.RawMessage(`{"type": "math", "fonts": ["missing.ttf"]}`)); err == nil {
+		t.Error("DriverFromConfig() with a missing font did not fail")
+	}
+}
@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"fmt"
 	"image/color"
 	"strings"
 
@@ -36,6 +37,9 @@ type DriverString struct {
 	//Fonts loads by name see fonts.go's comment
 	Fonts      []string
 	fontsArray []*truetype.Font
+
+	//Rand source of randomness (optional), a ChaCha8 stream seeded from crypto/rand by default
+	Rand RandSource
 }
 
 // NewDriverString creates driver
@@ -57,50 +61,85 @@ func NewDriverString(height int, width int, noiseCount int, showLineOptions int,
 	return &DriverString{Height: height, Width: width, NoiseCount: noiseCount, ShowLineOptions: showLineOptions, Length: length, Source: source, BgColor: bgColor, fontsStorage: fontsStorage, fontsArray: tfs, Fonts: fonts}
 }
 
-// ConvertFonts loads fonts by names
+// ConvertFonts loads fonts by names, it panics if a font cannot be loaded.
 func (d *DriverString) ConvertFonts() *DriverString {
+	if err := d.LoadFonts(); err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// LoadFonts loads fonts by names, or returns an error if a font cannot be
+// loaded.
+func (d *DriverString) LoadFonts() error {
 	if d.fontsStorage == nil {
 		d.fontsStorage = DefaultEmbeddedFonts
 	}
+	tfs, err := loadFonts(d.fontsStorage, d.Fonts)
+	if err != nil {
+		return err
+	}
+	d.fontsArray = tfs
+	return nil
+}
 
-	tfs := []*truetype.Font{}
-	for _, fff := range d.Fonts {
-		tf := d.fontsStorage.LoadFontByName("fonts/" + fff)
-		tfs = append(tfs, tf)
+// Validate checks the image size, the length and the source of the driver.
+func (d *DriverString) Validate() error {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return err
 	}
-	if len(tfs) == 0 {
-		tfs = fontsAll
+	if err := d.validateText(); err != nil {
+		return err
 	}
+	if d.NoiseCount < 0 {
+		return fmt.Errorf("%w: noise count %d must not be negative", ErrInvalidConfig, d.NoiseCount)
+	}
+	return nil
+}
 
-	d.fontsArray = tfs
-
-	return d
+// validateText checks the length and the source of the driver.
+func (d *DriverString) validateText() error {
+	if err := validateLength(d.Length); err != nil {
+		return err
+	}
+	if d.Source == "" {
+		return fmt.Errorf("%w: source must not be empty", ErrInvalidConfig)
+	}
+	return nil
 }
 
 // GenerateIdQuestionAnswer creates id,content and answer
 func (d *DriverString) GenerateIdQuestionAnswer() (id, content, answer string, _ error) {
-	id = RandomId()
-	content, err := RandText(d.Length, d.Source)
-	if err != nil {
+	return d.GenerateSpecificIdQuestionAnswer(RandomId())
+}
+
+// GenerateSpecificIdQuestionAnswer creates content and answer for the given id
+func (d *DriverString) GenerateSpecificIdQuestionAnswer(mId string) (id, content, answer string, _ error) {
+	if err := d.validateText(); err != nil {
 		return "", "", "", err
 	}
+	id = mId
+	content = newRandGen(d.Rand).text(d.Length, d.Source)
 	return id, content, content, nil
 }
 
 // DrawCaptcha draws captcha item
 func (d *DriverString) DrawCaptcha(content string) (item Item, _ error) {
+	if err := validateImageSize(d.Width, d.Height); err != nil {
+		return nil, err
+	}
+	if err := validateLength(d.Length); err != nil {
+		return nil, err
+	}
+	r := newRandGen(d.Rand)
 
 	var bgc color.RGBA
 	if d.BgColor != nil {
 		bgc = *d.BgColor
 	} else {
-		var err error
-		bgc, err = RandLightColor()
-		if err != nil {
-			return nil, err
-		}
+		bgc = r.lightColor()
 	}
-	itemChar := NewItemChar(d.Width, d.Height, bgc)
+	itemChar := newItemChar(r, d.Width, d.Height, bgc)
 
 	//draw hollow line
 	if d.ShowLineOptions&OptionShowHollowLine == OptionShowHollowLine {
@@ -120,11 +159,8 @@ func (d *DriverString) DrawCaptcha(content string) (item Item, _ error) {
 	//draw noise
 	if d.NoiseCount > 0 {
 		source := TxtNumbers + TxtAlphabet + ",.[]<>"
-		noise, err := RandText(d.NoiseCount, strings.Repeat(source, d.NoiseCount))
-		if err != nil {
-			return nil, err
-		}
-		err = itemChar.drawNoise(noise, d.fontsArray)
+		noise := r.text(d.NoiseCount, strings.Repeat(source, d.NoiseCount))
+		err := itemChar.drawNoise(noise, d.fontsArray)
 		if err != nil {
 			return nil, err
 		}
@@ -133,7 +169,7 @@ func (d *DriverString) DrawCaptcha(content string) (item Item, _ error) {
 	//draw content
 	err := itemChar.drawText(content, d.fontsArray)
 	if err != nil {
-		return
+		return nil, err
 	}
 
 	return itemChar, nil
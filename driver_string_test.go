@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"errors"
 	"image/color"
 	"reflect"
 	"testing"
@@ -130,3 +131,63 @@ func TestDriverString_GenerateIdQuestionAnswer(t *testing.T) {
 		})
 	}
 }
+
+func BenchmarkDriverString_DrawCaptcha(b *testing.B) {
+	benchmarkDrawCaptcha(b, func(src RandSource) Driver {
+		d := NewDriverString(80, 240, 100, OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, 5, TxtAlphabet+TxtNumbers, nil, nil, nil)
+		d.Rand = src
+		return d
+	})
+}
+
+func TestDriverString_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		d       *DriverString
+		wantErr bool
+	}{
+		{"valid", NewDriverString(60, 240, 10, OptionShowSineLine, 4, TxtAlphabet, nil, nil, nil), false},
+		{"zero size", &DriverString{Length: 4, Source: TxtAlphabet}, true},
+		{"no length", NewDriverString(60, 240, 10, 0, 0, TxtAlphabet, nil, nil, nil), true},
+		{"no source", NewDriverString(60, 240, 10, 0, 4, "", nil, nil, nil), true},
+		{"negative noise", NewDriverString(60, 240, -1, 0, 4, TxtAlphabet, nil, nil, nil), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.d.Validate()
+			if (err != nil) != tt.wantErr || (err != nil && !errors.Is(err, ErrInvalidConfig)) {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+	if _, err := (&DriverString{Width: 240, Height: 1}).DrawCaptcha("abcd"); !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("DrawCaptcha() error = %v, want ErrInvalidConfig", err)
+	}
+	for _, d := range []*DriverString{
+		NewDriverString(80, 240, 0, 0, 0, "abc", nil, nil, nil),
+		{Width: 240, Height: 80, Length: 4},
+	} {
+		if _, _, _, err := d.GenerateIdQuestionAnswer(); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("GenerateIdQuestionAnswer() of %+v error = %v, want ErrInvalidConfig", d, err)
+		}
+	}
+	d := NewDriverString(80, 240, 0, 0, 0, "abc", nil, nil, nil)
+	if item, err := d.DrawCaptcha("abcd"); item != nil || !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("DrawCaptcha() without length = %v, %v, want ErrInvalidConfig", item, err)
+	}
+	d.Length = 4
+	if item, err := d.DrawCaptcha(""); item != nil || err == nil {
+		t.Errorf("DrawCaptcha() of no text = %v, %v, want an error", item, err)
+	}
+}
+
+func TestDriverString_LoadFonts(t *testing.T) {
+	d := &DriverString{Fonts: []string{"RitaSmith.ttf"}}
+	if err := d.LoadFonts(); err != nil || len(d.fontsArray) != 1 {
+		t.Errorf("LoadFonts() error = %v, fonts = %d", err, len(d.fontsArray))
+	}
+	d = &DriverString{Fonts: []string{"missing.ttf"}}
+	if err := d.LoadFonts(); err == nil {
+		t.Error("LoadFonts() of a missing font did not fail")
+	}
+}
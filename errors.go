@@ -0,0 +1,65 @@
+package base64Captcha
+
+import "errors"
+
+// Verification outcomes returned by Captcha.Check and StoreContext.CheckContext.
+// Use errors.Is to tell them apart from store failures.
+var (
+	// ErrNotFound is returned when no captcha is stored under the id.
+	ErrNotFound = errors.New("captcha: not found")
+	// ErrExpired is returned when the captcha outlived the store expiration.
+	ErrExpired = errors.New("captcha: expired")
+	// ErrMismatch is returned when the answer does not match the captcha.
+	ErrMismatch = errors.New("captcha: answer mismatch")
+	// ErrAlreadyUsed is returned when the captcha has already been consumed.
+	ErrAlreadyUsed = errors.New("captcha: already used")
+	// ErrTooManyAttempts is returned when the captcha was invalidated after
+	// too many wrong answers.
+	ErrTooManyAttempts = errors.New("captcha: too many attempts")
+	// ErrInvalidToken is returned by StatelessCaptcha for a malformed or
+	// forged token, or one signed with an unknown key.
+	ErrInvalidToken = errors.New("captcha: invalid token")
+	// ErrBindingMismatch is returned when the captcha is verified under a
+	// different binding than the one it was generated with.
+	ErrBindingMismatch = errors.New("captcha: binding mismatch")
+)
+
+// ErrRecordUnsupported is returned when a Record needs a feature, such as an
+// attempt limit or a binding, that the underlying Store cannot enforce.
+var ErrRecordUnsupported = errors.New("captcha: store does not support record")
+
+// ErrReloadUnsupported is returned by Captcha.Reload when the driver does not
+// implement SpecificIdDriver.
+var ErrReloadUnsupported = errors.New("captcha: driver cannot generate a captcha for a given id")
+
+// ErrAudioUnsupported is returned by Captcha.GenerateAccessible when the
+// driver does not draw the digits of the answer, which the audio could speak.
+var ErrAudioUnsupported = errors.New("captcha: answer cannot be rendered as audio")
+
+// ErrOverloaded is returned by Captcha.Generate when the RenderLimiter of the
+// captcha did not get a rendering slot within its queue timeout.
+var ErrOverloaded = errors.New("captcha: too many captchas being rendered")
+
+// ErrRateLimited is returned by Captcha.GenerateContext when the client
+// exceeded the RateLimiter of the captcha.
+var ErrRateLimited = errors.New("captcha: rate limited")
+
+// ErrInvalidConfig is returned by the Validate method of drivers, and by
+// DrawCaptcha, when the config of the driver cannot produce a captcha.
+var ErrInvalidConfig = errors.New("captcha: invalid driver config")
+
+// ErrUnknownDriver is returned by DriverFromConfig and NewDriverByName when
+// no driver is registered under the requested type.
+var ErrUnknownDriver = errors.New("captcha: unknown driver")
+
+// isVerifyOutcome reports whether err describes a failed verification rather
+// than a store failure.
+func isVerifyOutcome(err error) bool {
+	return errors.Is(err, ErrNotFound) ||
+		errors.Is(err, ErrExpired) ||
+		errors.Is(err, ErrMismatch) ||
+		errors.Is(err, ErrAlreadyUsed) ||
+		errors.Is(err, ErrTooManyAttempts) ||
+		errors.Is(err, ErrInvalidToken) ||
+		errors.Is(err, ErrBindingMismatch)
+}
@@ -0,0 +1,30 @@
+package base64Captcha
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func Test_isVerifyOutcome(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"not-found", ErrNotFound, true},
+		{"expired", ErrExpired, true},
+		{"mismatch", ErrMismatch, true},
+		{"used", ErrAlreadyUsed, true},
+		{"binding", ErrBindingMismatch, true},
+		{"wrapped", fmt.Errorf("redis: %w", ErrExpired), true},
+		{"store", errors.New("connection refused"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isVerifyOutcome(tt.err); got != tt.want {
+				t.Errorf("isVerifyOutcome() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,8 +1,7 @@
 package base64Captcha
 
 import (
-	"crypto/rand"
-	"math/big"
+	"fmt"
 
 	"github.com/golang/freetype/truetype"
 )
@@ -30,22 +29,42 @@ var fontsSimple = DefaultEmbeddedFonts.LoadFontsByNames([]string{
 var fontsAll = append(fontsSimple, fontChinese)
 var fontChinese = DefaultEmbeddedFonts.LoadFontByName("fonts/wqy-microhei.ttc")
 
-// randFontFrom choose random font family.选择随机的字体
-func randFontFrom(fonts []*truetype.Font) (*truetype.Font, error) {
-	fontCount := len(fonts)
-
-	if fontCount == 0 {
-		//loading default fonts
-		fonts = fontsAll
-		fontCount = len(fontsAll)
+// loadFonts loads the fonts of the given names from the fonts directory of
+// storage, or returns fontsAll if there are none. Storages which do not
+// implement FontsLoader report errors by panicking, the panic is returned as
+// an error.
+func loadFonts(storage FontsStorage, names []string) (tfs []*truetype.Font, err error) {
+	if storage == nil {
+		storage = DefaultEmbeddedFonts
+	}
+	loader, ok := storage.(FontsLoader)
+	if !ok {
+		defer func() {
+			if r := recover(); r != nil {
+				tfs, err = nil, fmt.Errorf("captcha: loading fonts: %v", r)
+			}
+		}()
+	}
+	for _, name := range names {
+		var tf *truetype.Font
+		if ok {
+			if tf, err = loader.LoadFont("fonts/" + name); err != nil {
+				return nil, err
+			}
+		} else {
+			tf = storage.LoadFontByName("fonts/" + name)
+		}
+		tfs = append(tfs, tf)
 	}
-	// index := rand.Intn(fontCount)
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(fontCount)))
-	if err != nil {
-		return nil, err
+	if len(tfs) == 0 {
+		tfs = fontsAll
 	}
-	index := int(n.Int64())
-	return fonts[index], nil
+	return tfs, nil
+}
+
+// randFontFrom choose random font family.选择随机的字体
+func randFontFrom(fonts []*truetype.Font) (*truetype.Font, error) {
+	return newRandGen(nil).font(fonts), nil
 }
 
 var digitFontData = [][]byte{
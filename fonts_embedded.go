@@ -2,6 +2,7 @@ package base64Captcha
 
 import (
 	"embed"
+	"fmt"
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
@@ -11,19 +12,31 @@ type EmbeddedFontsStorage struct {
 	fs embed.FS
 }
 
-func (s *EmbeddedFontsStorage) LoadFontByName(name string) *truetype.Font {
+// LoadFont returns the font from the storage, or an error if it is missing
+// or malformed.
+func (s *EmbeddedFontsStorage) LoadFont(name string) (*truetype.Font, error) {
 	fontBytes, err := s.fs.ReadFile(name)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("captcha: loading font %s: %w", name, err)
 	}
 
 	//font file bytes to trueTypeFont
 	trueTypeFont, err := freetype.ParseFont(fontBytes)
 	if err != nil {
-		panic(err)
+		return nil, fmt.Errorf("captcha: parsing font %s: %w", name, err)
 	}
 
-	return trueTypeFont
+	return trueTypeFont, nil
+}
+
+// LoadFontByName is like LoadFont, but it panics if the font is missing or
+// malformed.
+func (s *EmbeddedFontsStorage) LoadFontByName(name string) *truetype.Font {
+	f, err := s.LoadFont(name)
+	if err != nil {
+		panic(err)
+	}
+	return f
 }
 
 // LoadFontsByNames import fonts from dir.
@@ -2,6 +2,8 @@ package base64Captcha
 
 import (
 	"testing"
+
+	"github.com/golang/freetype/truetype"
 )
 
 // sources:
@@ -53,3 +55,39 @@ func Test_randFontFrom(t *testing.T) {
 		t.Error("failed")
 	}
 }
+
+func TestEmbeddedFontsStorage_LoadFont(t *testing.T) {
+	if f, err := DefaultEmbeddedFonts.LoadFont("fonts/RitaSmith.ttf"); err != nil || f == nil {
+		t.Errorf("LoadFont() = %v, %v", f, err)
+	}
+	for _, name := range []string{"fonts/missing.ttf", "fonts/readme.md"} {
+		if _, err := DefaultEmbeddedFonts.LoadFont(name); err == nil {
+			t.Errorf("LoadFont(%q) did not fail", name)
+		}
+	}
+}
+
+// panickingFontsStorage is a FontsStorage which does not implement
+// FontsLoader.
+type panickingFontsStorage struct{}
+
+func (panickingFontsStorage) LoadFontByName(name string) *truetype.Font {
+	panic("no font " + name)
+}
+
+func (panickingFontsStorage) LoadFontsByNames(names []string) []*truetype.Font {
+	return nil
+}
+
+func Test_loadFonts(t *testing.T) {
+	tfs, err := loadFonts(nil, nil)
+	if err != nil || len(tfs) != len(fontsAll) {
+		t.Errorf("loadFonts() of no names = %d fonts, %v", len(tfs), err)
+	}
+	if _, err := loadFonts(DefaultEmbeddedFonts, []string{"missing.ttf"}); err == nil {
+		t.Error("loadFonts() of a missing font did not fail")
+	}
+	if _, err := loadFonts(panickingFontsStorage{}, []string{"RitaSmith.ttf"}); err == nil {
+		t.Error("loadFonts() did not turn the panic into an error")
+	}
+}
@@ -7,3 +7,18 @@ type Driver interface {
 	//GenerateIdQuestionAnswer creates rand id, content and answer
 	GenerateIdQuestionAnswer() (id, q, a string, _ error)
 }
+
+// Validator is implemented by drivers which can check their config. The
+// built-in drivers implement it, DriverFromConfig and NewDriverByName
+// refuse drivers which do not validate.
+type Validator interface {
+	//Validate returns an error wrapping ErrInvalidConfig for a bad config
+	Validate() error
+}
+
+// SpecificIdDriver is implemented by drivers which can generate a captcha
+// for a given id. Captcha.Reload needs it.
+type SpecificIdDriver interface {
+	//GenerateSpecificIdQuestionAnswer creates content and answer for the id
+	GenerateSpecificIdQuestionAnswer(mId string) (id, q, a string, _ error)
+}
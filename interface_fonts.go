@@ -10,3 +10,10 @@ type FontsStorage interface {
 	// LoadFontsByNames returns multiple fonts from storage
 	LoadFontsByNames(assetFontNames []string) []*truetype.Font
 }
+
+// FontsLoader is implemented by font storages which report a missing or
+// malformed font with an error instead of a panic.
+type FontsLoader interface {
+	// LoadFont returns the font from the storage
+	LoadFont(name string) (*truetype.Font, error)
+}
@@ -1,11 +1,34 @@
 package base64Captcha
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // Item is captcha item interface
 type Item interface {
 	//WriteTo writes to a writer
 	WriteTo(w io.Writer) (n int64, err error)
-	//EncodeB64string encodes as base64 string
-	EncodeB64string() string
+	//EncodeB64string encodes as base64 data URI string
+	EncodeB64string() (string, error)
+	//WriteDataURI writes the base64 data URI to a writer
+	WriteDataURI(w io.Writer) (n int64, err error)
+	//ContentType returns the MIME type of the encoded item
+	ContentType() string
+}
+
+// ImageItem is implemented by items which are images.
+type ImageItem interface {
+	Item
+	//Width returns the width of the image in pixels
+	Width() int
+	//Height returns the height of the image in pixels
+	Height() int
+}
+
+// AudioItem is implemented by items which are sounds.
+type AudioItem interface {
+	Item
+	//Duration returns the length of the sound
+	Duration() time.Duration
 }
@@ -1,5 +1,10 @@
 package base64Captcha
 
+import (
+	"context"
+	"time"
+)
+
 // Store An object implementing Store interface can be registered with SetCustomStore
 // function to handle storage and retrieval of captcha ids and solutions for
 // them, replacing the default memory store.
@@ -18,3 +23,82 @@ type Store interface {
 	//Verify captcha's answer directly
 	Verify(id, answer string, clear bool) bool
 }
+
+// StoreContext is the context-aware counterpart of Store. Stores backed by a
+// network service (Redis, SQL, ...) should implement it so that they can honor
+// request deadlines and report failures instead of returning empty values.
+//
+// CheckContext returns the outcome of a failed verification, such as
+// ErrMismatch or ErrExpired, as an error; VerifyContext reports it as a
+// false match with a nil error. Any other error means the store could not
+// answer.
+type StoreContext interface {
+	// SetContext sets the digits for the captcha id.
+	SetContext(ctx context.Context, id string, value string) error
+
+	// SetRecordContext stores a structured record for the captcha id.
+	SetRecordContext(ctx context.Context, id string, rec Record) error
+
+	// GetContext returns stored digits for the captcha id. Clear indicates
+	// whether the captcha must be deleted from the store.
+	GetContext(ctx context.Context, id string, clear bool) (string, error)
+
+	// VerifyContext verifies captcha's answer directly.
+	VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error)
+
+	// CheckContext verifies captcha's answer and tells why it failed. It
+	// returns nil on a match, one of ErrNotFound, ErrExpired, ErrMismatch,
+	// ErrAlreadyUsed, ErrTooManyAttempts and ErrBindingMismatch on a failed
+	// verification, or a store error.
+	CheckContext(ctx context.Context, id, answer string, clear bool) error
+}
+
+// ReplaceStore is implemented by stores which can replace the record of a
+// live captcha atomically. Captcha.ReloadContext uses it so that a captcha
+// consumed meanwhile is not revived, and that a client cannot replace the
+// captcha of another one; with other stores it checks that the captcha is
+// live, then stores the new record.
+type ReplaceStore interface {
+	// ReplaceRecordContext replaces the record of captcha id with rec if
+	// the captcha can be verified and is bound to the binding of ctx. It
+	// returns ErrBindingMismatch if the captcha is bound to another one,
+	// one of ErrNotFound, ErrExpired, ErrAlreadyUsed and ErrTooManyAttempts
+	// if it cannot be verified any more, or a store error.
+	ReplaceRecordContext(ctx context.Context, id string, rec Record) error
+}
+
+// ExpiringStore is implemented by stores which keep captchas for a fixed
+// time, so that Captcha.GenerateResult can tell when a captcha expires.
+type ExpiringStore interface {
+	// Expiration returns how long captchas are kept.
+	Expiration() time.Duration
+}
+
+// ExpireNotifier is implemented by stores which delete the expired captchas
+// themselves and can tell those which were never used. Captcha registers
+// its Observer with the store, see Observer.Expired. Stores relying on the
+// expiry of their backend, such as Redis and Memcache, cannot implement it.
+type ExpireNotifier interface {
+	// OnExpire sets the function called for every captcha deleted after it
+	// expired unused, replacing the previous one. A nil fn removes it. fn
+	// is called without the locks of the store held.
+	OnExpire(fn func(e ExpireEvent))
+}
+
+// Record is what a StoreContext keeps for a captcha besides its id.
+type Record struct {
+	// Answer is the captcha solution.
+	Answer string
+	// MaxAttempts is the number of wrong answers after which the captcha
+	// is invalidated. While it is positive, a wrong answer does not consume
+	// the captcha even if clear is true, so users can fix a typo.
+	// Zero keeps the classic behavior of Verify.
+	MaxAttempts int
+	// Binding ties the captcha to a client, see ContextWithBinding. It is
+	// compared with the binding of the context passed to CheckContext and
+	// VerifyContext, and a mismatch counts as a wrong answer.
+	Binding string
+	// Driver is the type name of the driver which drew the captcha, such
+	// as "DriverDigit". Stores keep it to label the events of the Observer.
+	Driver string
+}
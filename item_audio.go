@@ -6,12 +6,9 @@ package base64Captcha
 
 import (
 	"bytes"
-	"crypto/rand"
-	"encoding/base64"
 	"encoding/binary"
-	"fmt"
 	"io"
-	"math/big"
+	"time"
 )
 
 // ItemAudio captcha-audio-engine return type.
@@ -19,15 +16,16 @@ type ItemAudio struct {
 	answer      string
 	body        *bytes.Buffer
 	digitSounds [][]byte
-	//rng         siprng
+	rand        randGen
 }
 
 // newAudio returns a new audio captcha with the given digits, where each digit
 // must be in range 0-9. Digits are pronounced in the given language. If there
 // are no sounds for the given language, English is used.
-// Possible values for lang are "en", "ja", "ru", "zh".
-func newAudio(id string, digits []byte, lang string) (*ItemAudio, error) {
-	a := new(ItemAudio)
+// Possible values for lang are "en", "ja", "ru", "zh". Randomness is drawn
+// from r.
+func newAudio(r randGen, id string, digits []byte, lang string) (*ItemAudio, error) {
+	a := &ItemAudio{rand: r}
 
 	if sounds, ok := digitSounds[lang]; ok {
 		a.digitSounds = sounds
@@ -47,10 +45,7 @@ func newAudio(id string, digits []byte, lang string) (*ItemAudio, error) {
 	intervals := make([]int, len(digits)+1)
 	intdur := 0
 	for i := range intervals {
-		dur, err := randIntRange(sampleRate, sampleRate*2) // 1 to 2 seconds
-		if err != nil {
-			return nil, err
-		}
+		dur := a.rand.intRange(sampleRate, sampleRate*2) // 1 to 2 seconds
 		intdur += dur
 		intervals[i] = dur
 	}
@@ -92,21 +87,9 @@ func (a *ItemAudio) makeBackgroundSound(length int) ([]byte, error) {
 		return nil, err
 	}
 	for i := 0; i < length/(sampleRate/10); i++ {
-		n, err := rand.Int(rand.Reader, big.NewInt(10))
-		if err != nil {
-			return nil, err
-		}
-		snd := reversedSound(a.digitSounds[int(n.Int64())])
-		placeN, err := rand.Int(rand.Reader, big.NewInt(int64(len(b)-len(snd))))
-		if err != nil {
-			return nil, err
-		}
-		place := int(placeN.Int64())
-		randFRange, err := randFloat64Range(0.04, 0.08)
-		if err != nil {
-			return nil, err
-		}
-		setSoundLevel(snd, randFRange)
+		snd := reversedSound(a.digitSounds[a.rand.IntN(10)])
+		place := a.rand.intN(len(b) - len(snd))
+		setSoundLevel(snd, a.rand.float64Range(0.04, 0.08))
 		mixSound(b[place:], snd)
 	}
 	return b, nil
@@ -117,11 +100,7 @@ func (a *ItemAudio) randomizedDigitSound(n byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	randFRange, err := randFloat64Range(0.85, 1.2)
-	if err != nil {
-		return nil, err
-	}
-	setSoundLevel(s, randFRange)
+	setSoundLevel(s, a.rand.float64Range(0.85, 1.2))
 	return s, nil
 }
 
@@ -136,18 +115,11 @@ func (a *ItemAudio) longestDigitSndLen() int {
 }
 
 func (a *ItemAudio) randomSpeed(b []byte) ([]byte, error) {
-	pitch, err := randFloat64Range(0.95, 1.1)
-	if err != nil {
-		return nil, err
-	}
-	return changeSpeed(b, pitch), nil
+	return changeSpeed(b, a.rand.float64Range(0.95, 1.1)), nil
 }
 
 func (a *ItemAudio) makeWhiteNoise(length int, level uint8) ([]byte, error) {
-	noise, err := randBytes(length)
-	if err != nil {
-		return nil, err
-	}
+	noise := a.rand.bytes(length)
 	adj := 128 - level/2
 	for i, v := range noise {
 		v %= level
@@ -180,8 +152,9 @@ func (a *ItemAudio) WriteTo(w io.Writer) (n int64, err error) {
 	if err != nil {
 		return
 	}
-	// Write data.
-	n, err = a.body.WriteTo(w)
+	// Write data, leaving the body unread so that the item can be written
+	// again.
+	nn, err = w.Write(a.body.Bytes())
 	n += int64(nn)
 	if err != nil {
 		return
@@ -189,18 +162,30 @@ func (a *ItemAudio) WriteTo(w io.Writer) (n int64, err error) {
 	// Pad byte if chunk length is odd.
 	// (As header has even length, we can check if n is odd, not chunk).
 	if bodyLen != paddedBodyLen {
-		w.Write([]byte{0})
-		n++
+		nn, err = w.Write([]byte{0})
+		n += int64(nn)
 	}
 	return
 }
 
+// ContentType returns the MIME type of the WAV sound.
+func (a *ItemAudio) ContentType() string {
+	return MimeTypeAudio
+}
+
+// Duration returns the length of the sound.
+func (a *ItemAudio) Duration() time.Duration {
+	// The sound is 8-bit mono PCM, a byte per sample.
+	return time.Duration(a.body.Len()) * time.Second / sampleRate
+}
+
 // EncodeB64string encodes a sound to base64 string
-func (a *ItemAudio) EncodeB64string() string {
-	var buf bytes.Buffer
-	if _, err := a.WriteTo(&buf); err != nil {
-		panic(err)
-	}
-	return fmt.Sprintf("data:%s;base64,%s", MimeTypeAudio, base64.StdEncoding.EncodeToString(buf.Bytes()))
+func (a *ItemAudio) EncodeB64string() (string, error) {
+	return encodeDataURI(MimeTypeAudio, a)
+}
 
+// WriteDataURI writes the sound into the given io.Writer as a base64 data
+// URI, and returns the number of bytes written and an error if any.
+func (a *ItemAudio) WriteDataURI(w io.Writer) (int64, error) {
+	return writeDataURI(w, MimeTypeAudio, a)
 }
@@ -29,7 +29,7 @@ func Test_newAudio(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, err := newAudio(tt.args.id, tt.args.digits, tt.args.lang)
+			got, err := newAudio(newRandGen(nil), tt.args.id, tt.args.digits, tt.args.lang)
 			if err != nil {
 				t.Errorf("newAudio() error = %v", err)
 				return
@@ -42,7 +42,7 @@ func Test_newAudio(t *testing.T) {
 }
 
 func TestItemAudio_encodedLen(t *testing.T) {
-	ia, err := newAudio(RandomId(), randomDigits(3), "zh")
+	ia, err := newAudio(newRandGen(nil), RandomId(), randomDigits(3), "zh")
 	if err != nil {
 		t.Error("failed", err)
 	}
@@ -81,7 +81,7 @@ func TestItemAudio_longestDigitSndLen(t *testing.T) {
 }
 
 func TestItemAudio_WriteTo(t *testing.T) {
-	ia, err := newAudio(RandomId(), randomDigits(3), "zh")
+	ia, err := newAudio(newRandGen(nil), RandomId(), randomDigits(3), "zh")
 	if err != nil {
 		t.Error("failed", err)
 	}
@@ -112,8 +112,33 @@ func TestItemAudio_WriteTo(t *testing.T) {
 	}
 }
 
+func TestItemAudio_WriteToTwice(t *testing.T) {
+	a, err := newAudio(newRandGen(nil), "", []byte{4, 2}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var first, second bytes.Buffer
+	if _, err := a.WriteTo(&first); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.WriteTo(&second); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(first.Bytes(), second.Bytes()) {
+		t.Errorf("WriteTo() wrote %d bytes, then %d", first.Len(), second.Len())
+	}
+	b64s, err := a.EncodeB64string()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var uri bytes.Buffer
+	if _, err := a.WriteDataURI(&uri); err != nil || uri.String() != b64s {
+		t.Errorf("WriteDataURI() = %.40s..., %v, want %.40s...", uri.String(), err, b64s)
+	}
+}
+
 func TestItemAudio_EncodeB64string(t *testing.T) {
-	ia, err := newAudio(RandomId(), randomDigits(5), "en")
+	ia, err := newAudio(newRandGen(nil), RandomId(), randomDigits(5), "en")
 	if err != nil {
 		t.Error("failed", err)
 	}
@@ -126,9 +151,24 @@ func TestItemAudio_EncodeB64string(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.a.EncodeB64string(); len(got) < 1 {
-				t.Errorf("ItemAudio.EncodeB64string() = %v, want %v", got, tt.want)
+			if got, err := tt.a.EncodeB64string(); err != nil || len(got) < 1 {
+				t.Errorf("ItemAudio.EncodeB64string() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
 }
+
+func TestItemAudio_metadata(t *testing.T) {
+	a, err := newAudio(newRandGen(nil), "", []byte{1, 2, 3}, "en")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var snd AudioItem = a
+	if snd.ContentType() != MimeTypeAudio {
+		t.Errorf("ContentType() = %q", snd.ContentType())
+	}
+	// Three digits and four silences of one to two seconds.
+	if d := snd.Duration(); d < 4*time.Second || d > 12*time.Second {
+		t.Errorf("Duration() = %v", d)
+	}
+}
@@ -1,19 +1,14 @@
 package base64Captcha
 
 import (
-	"bytes"
-	"crypto/rand"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"image/draw"
-	"image/png"
 	"io"
 	"log"
 	"math"
-	"math/big"
 
 	"github.com/golang/freetype"
 	"github.com/golang/freetype/truetype"
@@ -26,11 +21,17 @@ type ItemChar struct {
 	width   int
 	height  int
 	nrgba   *image.NRGBA
+	rand    randGen
 }
 
 // NewItemChar creates a captcha item of characters
 func NewItemChar(w int, h int, bgColor color.RGBA) *ItemChar {
-	d := ItemChar{width: w, height: h}
+	return newItemChar(newRandGen(nil), w, h, bgColor)
+}
+
+// newItemChar creates a captcha item of characters drawing randomness from r
+func newItemChar(r randGen, w int, h int, bgColor color.RGBA) *ItemChar {
+	d := ItemChar{width: w, height: h, rand: r}
 	m := image.NewNRGBA(image.Rect(0, 0, w, h))
 	draw.Draw(m, m.Bounds(), &image.Uniform{bgColor}, image.ZP, draw.Src)
 	d.nrgba = m
@@ -43,33 +44,15 @@ func (item *ItemChar) drawHollowLine() (*ItemChar, error) {
 	first := item.width / 20
 	end := first * 19
 
-	lineColor, err := RandLightColor()
-	if err != nil {
-		return item, err
-	}
+	lineColor := item.rand.lightColor()
 
-	// x1 := float64(rand.Intn(first))
-	x1n, err := rand.Int(rand.Reader, big.NewInt(int64(first)))
-	if err != nil {
-		return item, err
-	}
-	x1 := float64(x1n.Int64())
+	x1 := float64(item.rand.intN(first))
 	//y1 := float64(rand.Intn(y)+y);
 
-	// x2 := float64(rand.Intn(first) + end)
-	x2n, err := rand.Int(rand.Reader, big.NewInt(int64(first)))
-	if err != nil {
-		return item, err
-	}
-	x2 := float64(x2n.Int64() + int64(end))
+	x2 := float64(item.rand.intN(first) + end)
 	//y2 := float64(rand.Intn(y)+y);
 
-	// multiple := float64(rand.Intn(5)+3) / float64(5)
-	multipleN, err := rand.Int(rand.Reader, big.NewInt(8))
-	if err != nil {
-		return item, err
-	}
-	multiple := float64(multipleN.Int64()+3) / float64(5)
+	multiple := float64(item.rand.intN(8)+3) / float64(5)
 	if int(multiple*10)%3 == 0 {
 		multiple = multiple * -1.0
 	}
@@ -98,48 +81,29 @@ func (item *ItemChar) drawSineLine() (*ItemChar, error) {
 	var py float64
 
 	//振幅
-	aN, err := rand.Int(rand.Reader, big.NewInt(int64(item.height/2)))
-	if err != nil {
-		return item, err
-	}
-	a := int(aN.Int64())
+	a := item.rand.intN(item.height / 2)
 
 	//Y轴方向偏移量
-	b, err := random(int64(-item.height/4), int64(item.height/4))
-	if err != nil {
-		return item, err
-	}
+	b := item.rand.random(int64(-item.height/4), int64(item.height/4))
 
 	//X轴方向偏移量
-	f, err := random(int64(-item.height/4), int64(item.height/4))
-	if err != nil {
-		return item, err
-	}
+	f := item.rand.random(int64(-item.height/4), int64(item.height/4))
 	// 周期
 	var t float64
 	if item.height > item.width/2 {
-		t, err = random(int64(item.width/2), int64(item.height))
-		if err != nil {
-			return item, err
-		}
+		t = item.rand.random(int64(item.width/2), int64(item.height))
 	} else if item.height == item.width/2 {
 		t = float64(item.height)
 	} else {
-		t, err = random(int64(item.height), int64(item.width/2))
-		if err != nil {
-			return item, err
-		}
+		t = item.rand.random(int64(item.height), int64(item.width/2))
 	}
 	w := float64((2 * math.Pi) / t)
 
 	// 曲线横坐标起始位置
 	px1 := 0
-	px2, err := random(int64(float64(item.width)*0.8), int64(item.width))
+	px2 := item.rand.random(int64(float64(item.width)*0.8), int64(item.width))
 
-	c, err := RandDeepColor()
-	if err != nil {
-		return item, err
-	}
+	c := item.rand.deepColor()
 
 	for px := px1; px < int(px2); px++ {
 		if w != 0 {
@@ -166,60 +130,18 @@ func (item *ItemChar) drawSlimLine(num int) (*ItemChar, error) {
 
 	for i := 0; i < num; i++ {
 
-		// point1 := point{X: rand.Intn(first), Y: rand.Intn(y)}
-		x1n, err := rand.Int(rand.Reader, big.NewInt(int64(first)))
-		if err != nil {
-			return item, err
-		}
-		y1n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-		if err != nil {
-			return item, err
-		}
-		point1 := point{X: int(x1n.Int64()), Y: int(y1n.Int64())}
-
-		// point2 := point{X: rand.Intn(first) + end, Y: rand.Intn(y)}
-		x2n, err := rand.Int(rand.Reader, big.NewInt(int64(first)))
-		if err != nil {
-			return item, err
-		}
-		y2n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-		if err != nil {
-			return item, err
-		}
-		point2 := point{X: int(x2n.Int64()) + end, Y: int(y2n.Int64())}
+		point1 := point{X: item.rand.intN(first), Y: item.rand.intN(y)}
+		point2 := point{X: item.rand.intN(first) + end, Y: item.rand.intN(y)}
 
 		if i%2 == 0 {
-			// point1.Y = rand.Intn(y) + y*2
-			y1n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-			if err != nil {
-				return item, err
-			}
-			point1.Y = int(y1n.Int64()) + y*2
-			// point2.Y = rand.Intn(y)
-			y2n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-			if err != nil {
-				return item, err
-			}
-			point2.Y = int(y2n.Int64())
+			point1.Y = item.rand.intN(y) + y*2
+			point2.Y = item.rand.intN(y)
 		} else {
-			// point1.Y = rand.Intn(y) + y*(i%2)
-			y1n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-			if err != nil {
-				return item, err
-			}
-			point1.Y = int(y1n.Int64()) + y*(i%2)
-			// point2.Y = rand.Intn(y) + y*2
-			y2n, err := rand.Int(rand.Reader, big.NewInt(int64(y)))
-			if err != nil {
-				return item, err
-			}
-			point2.Y = int(y2n.Int64()) + y*2
+			point1.Y = item.rand.intN(y) + y*(i%2)
+			point2.Y = item.rand.intN(y) + y*2
 		}
 
-		randDeepColor, err := RandDeepColor()
-		if err != nil {
-			return item, err
-		}
+		randDeepColor := item.rand.deepColor()
 		item.drawBeeline(point1, point2, randDeepColor)
 
 	}
@@ -267,40 +189,15 @@ func (item *ItemChar) drawNoise(noiseText string, fonts []*truetype.Font) error
 	c.SetClip(item.nrgba.Bounds())
 	c.SetDst(item.nrgba)
 	c.SetHinting(font.HintingFull)
-	// rawFontSize := float64(item.height) / (1 + float64(rand.Intn(7))/float64(10))
-	rfsN, err := rand.Int(rand.Reader, big.NewInt(7))
-	if err != nil {
-		return err
-	}
-	rawFontSize := float64(item.height) / (1 + float64(rfsN.Int64())/float64(10))
+	rawFontSize := float64(item.height) / (1 + float64(item.rand.intN(7))/float64(10))
 
 	for _, char := range noiseText {
-		rwN, err := rand.Int(rand.Reader, big.NewInt(int64(item.width)))
-		if err != nil {
-			return err
-		}
-		rw := int(rwN.Int64())
-		rhN, err := rand.Int(rand.Reader, big.NewInt(int64(item.height)))
-		if err != nil {
-			return err
-		}
-		rh := int(rhN.Int64())
-		fsN, err := rand.Int(rand.Reader, big.NewInt(5))
-		if err != nil {
-			return err
-		}
-		fontSize := rawFontSize/2 + float64(fsN.Int64())
-		randLightColor, err := RandLightColor()
-		if err != nil {
-			return err
-		}
-		c.SetSrc(image.NewUniform(randLightColor))
+		rw := item.rand.intN(item.width)
+		rh := item.rand.intN(item.height)
+		fontSize := rawFontSize/2 + float64(item.rand.intN(5))
+		c.SetSrc(image.NewUniform(item.rand.lightColor()))
 		c.SetFontSize(fontSize)
-		randFonts, err := randFontFrom(fonts)
-		if err != nil {
-			return err
-		}
-		c.SetFont(randFonts)
+		c.SetFont(item.rand.font(fonts))
 		pt := freetype.Pt(rw, rh)
 		if _, err := c.DrawString(string(char), pt); err != nil {
 			log.Println(err)
@@ -321,32 +218,19 @@ func (item *ItemChar) drawText(text string, fonts []*truetype.Font) error {
 	if len(text) == 0 {
 		return errors.New("text must not be empty, there is nothing to draw")
 	}
+	if item.height*7/16 < 1 {
+		return fmt.Errorf("%w: image of %d pixels high is too small to draw text", ErrInvalidConfig, item.height)
+	}
 
 	fontWidth := item.width / len(text)
 
 	for i, s := range text {
-		fsN, err := rand.Int(rand.Reader, big.NewInt(7))
-		if err != nil {
-			return err
-		}
-		fontSize := item.height * (int(fsN.Int64()) + 7) / 16
-		src, err := RandDeepColor()
-		if err != nil {
-			return err
-		}
-		c.SetSrc(image.NewUniform(src))
+		fontSize := item.height * (item.rand.intN(7) + 7) / 16
+		c.SetSrc(image.NewUniform(item.rand.deepColor()))
 		c.SetFontSize(float64(fontSize))
-		randFont, err := randFontFrom(fonts)
-		if err != nil {
-			return err
-		}
-		c.SetFont(randFont)
+		c.SetFont(item.rand.font(fonts))
 		x := fontWidth*i + fontWidth/fontSize
-		rhN, err := rand.Int(rand.Reader, big.NewInt(int64(item.height/16*3)))
-		if err != nil {
-			return err
-		}
-		y := item.height/2 + fontSize/2 - int(rhN.Int64())
+		y := item.height/2 + fontSize/2 - item.rand.intN(item.height/16*3)
 		pt := freetype.Pt(x, y)
 		if _, err := c.DrawString(string(s), pt); err != nil {
 			return err
@@ -356,24 +240,40 @@ func (item *ItemChar) drawText(text string, fonts []*truetype.Font) error {
 }
 
 // BinaryEncoding encodes an image to PNG and returns a byte slice.
-func (item *ItemChar) BinaryEncoding() []byte {
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, item.nrgba); err != nil {
-		panic(err.Error())
-	}
-	return buf.Bytes()
+func (item *ItemChar) BinaryEncoding() ([]byte, error) {
+	return encodeBinary(item)
 }
 
 // WriteTo writes captcha character in png format into the given io.Writer, and
 // returns the number of bytes written and an error if any.
 func (item *ItemChar) WriteTo(w io.Writer) (int64, error) {
-	n, err := w.Write(item.BinaryEncoding())
-	return int64(n), err
+	return writePNG(w, item.nrgba)
+}
+
+// WriteDataURI writes the image into the given io.Writer as a base64 data
+// URI, and returns the number of bytes written and an error if any.
+func (item *ItemChar) WriteDataURI(w io.Writer) (int64, error) {
+	return writeDataURI(w, MimeTypeImage, item)
+}
+
+// ContentType returns the MIME type of the PNG image.
+func (item *ItemChar) ContentType() string {
+	return MimeTypeImage
+}
+
+// Width returns the width of the image in pixels.
+func (item *ItemChar) Width() int {
+	return item.width
+}
+
+// Height returns the height of the image in pixels.
+func (item *ItemChar) Height() int {
+	return item.height
 }
 
 // EncodeB64string encodes an image to base64 string
-func (item *ItemChar) EncodeB64string() string {
-	return fmt.Sprintf("data:%s;base64,%s", MimeTypeImage, base64.StdEncoding.EncodeToString(item.BinaryEncoding()))
+func (item *ItemChar) EncodeB64string() (string, error) {
+	return encodeDataURI(MimeTypeImage, item)
 }
 
 type point struct {
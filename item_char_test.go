@@ -175,8 +175,8 @@ func TestItemChar_BinaryEncoding(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.item.BinaryEncoding(); !reflect.DeepEqual(got, tt.want) {
-				t.Errorf("ItemChar.BinaryEncoding() = %v, want %v", got, tt.want)
+			if got, err := tt.item.BinaryEncoding(); err != nil || !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ItemChar.BinaryEncoding() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
@@ -220,9 +220,29 @@ func TestItemChar_EncodeB64string(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.item.EncodeB64string(); got != tt.want {
-				t.Errorf("ItemChar.EncodeB64string() = %v, want %v", got, tt.want)
+			if got, err := tt.item.EncodeB64string(); err != nil || got != tt.want {
+				t.Errorf("ItemChar.EncodeB64string() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
 }
+
+func TestItemChar_metadata(t *testing.T) {
+	var item ImageItem = NewItemChar(240, 80, color.RGBA{})
+	if item.ContentType() != MimeTypeImage || item.Width() != 240 || item.Height() != 80 {
+		t.Errorf("item = %s %dx%d", item.ContentType(), item.Width(), item.Height())
+	}
+}
+
+func TestItemChar_WriteDataURI(t *testing.T) {
+	item := NewItemChar(120, 40, color.RGBA{R: 200, A: 255})
+	b64s, err := item.EncodeB64string()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := item.WriteDataURI(&buf)
+	if err != nil || buf.String() != b64s || n != int64(len(b64s)) {
+		t.Errorf("WriteDataURI() = %d, %v, want %.40s...", n, err, b64s)
+	}
+}
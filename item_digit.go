@@ -1,16 +1,11 @@
 package base64Captcha
 
 import (
-	"bytes"
-	"crypto/rand"
-	"encoding/base64"
 	"fmt"
 	"image"
 	"image/color"
-	"image/png"
 	"io"
 	"math"
-	"math/big"
 )
 
 const (
@@ -27,14 +22,19 @@ type ItemDigit struct {
 	dotSize  int
 	dotCount int
 	maxSkew  float64
-	//rng      siprng
+	rand     randGen
 }
 
 // NewItemDigit create a instance of item-digit
 func NewItemDigit(width int, height int, dotCount int, maxSkew float64) (*ItemDigit, error) {
-	itemDigit := &ItemDigit{width: width, height: height, dotCount: dotCount, maxSkew: maxSkew}
+	return newItemDigit(newRandGen(nil), width, height, dotCount, maxSkew)
+}
+
+// newItemDigit create a instance of item-digit drawing randomness from r
+func newItemDigit(r randGen, width int, height int, dotCount int, maxSkew float64) (*ItemDigit, error) {
+	itemDigit := &ItemDigit{width: width, height: height, dotCount: dotCount, maxSkew: maxSkew, rand: r}
 	//init image.Paletted
-	colorPalette, err := createRandPaletteColors(dotCount)
+	colorPalette, err := createRandPaletteColors(r, dotCount)
 	if err != nil {
 		return nil, err
 	}
@@ -42,26 +42,14 @@ func NewItemDigit(width int, height int, dotCount int, maxSkew float64) (*ItemDi
 	return itemDigit, nil
 }
 
-func createRandPaletteColors(dotCount int) (color.Palette, error) {
+func createRandPaletteColors(r randGen, dotCount int) (color.Palette, error) {
 	p := make([]color.Color, dotCount+1)
 	// Transparent color.
 	p[0] = color.RGBA{0xFF, 0xFF, 0xFF, 0x00}
 	// Primary color.
-	GN, err := rand.Int(rand.Reader, big.NewInt(129))
-	if err != nil {
-		return nil, err
-	}
-	green := int(GN.Int64())
-	RN, err := rand.Int(rand.Reader, big.NewInt(129))
-	if err != nil {
-		return nil, err
-	}
-	red := int(RN.Int64())
-	BN, err := rand.Int(rand.Reader, big.NewInt(129))
-	if err != nil {
-		return nil, err
-	}
-	blue := int(BN.Int64())
+	green := r.IntN(129)
+	red := r.IntN(129)
+	blue := r.IntN(129)
 	prim := color.RGBA{
 		uint8(red),
 		uint8(green),
@@ -71,20 +59,36 @@ func createRandPaletteColors(dotCount int) (color.Palette, error) {
 
 	if dotCount == 0 {
 		p[0] = prim
-		return p, fmt.Errorf("dotCount must be greater than 0")
+		return p, fmt.Errorf("%w: dotCount must be greater than 0", ErrInvalidConfig)
 	}
 
 	p[1] = prim
 	// Circle colors.
 	for i := 2; i <= dotCount; i++ {
-		p[i], err = randomBrightness(prim, 255)
-		if err != nil {
-			return nil, err
-		}
+		p[i] = randomBrightness(r, prim, 255)
 	}
 	return p, nil
 }
 
+// layout sizes n digits to fit a width x height image, and returns the
+// ranges of the random position of the digits and the border around them.
+// It fails if the digits do not fit in the image.
+func (m *ItemDigit) layout(width, height, n int) (xRange, yRange, border int, err error) {
+	m.calculateSizes(width, height, n)
+	maxx := width - (m.width+m.dotSize)*n - m.dotSize
+	maxy := height - m.height - m.dotSize*2
+	if width > height {
+		border = height / 5
+	} else {
+		border = width / 5
+	}
+	xRange, yRange = maxx-border*2, maxy-border*2
+	if m.width <= 0 || m.height <= 0 || xRange <= 0 || yRange <= 0 {
+		return 0, 0, 0, fmt.Errorf("%w: image of %dx%d pixels is too small for %d digits", ErrInvalidConfig, width, height, n)
+	}
+	return xRange, yRange, border, nil
+}
+
 func (m *ItemDigit) calculateSizes(width, height, ncount int) {
 	// Goal: fit all digits inside the image.
 	var border int
@@ -159,26 +163,9 @@ func (m *ItemDigit) fillWithCircles(n, maxradius int) error {
 	maxx := m.Bounds().Max.X
 	maxy := m.Bounds().Max.Y
 	for i := 0; i < n; i++ {
-		//colorIdx := uint8(m.rng.Int(1, m.dotCount-1))
-		colorIdx, err := randIntRange(1, m.dotCount-1)
-		if err != nil {
-			return err
-		}
-		//r := m.rng.Int(1, maxradius)
-		r, err := randIntRange(1, maxradius)
-		if err != nil {
-			return err
-		}
-		//m.drawCircle(m.rng.Int(r, maxx-r), m.rng.Int(r, maxy-r), r, colorIdx)
-		x, err := randIntRange(r, maxx-r)
-		if err != nil {
-			return err
-		}
-		y, err := randIntRange(r, maxy-r)
-		if err != nil {
-			return err
-		}
-		m.drawCircle(x, y, r, uint8(colorIdx))
+		colorIdx := m.rand.intRange(1, m.dotCount-1)
+		r := m.rand.intRange(1, maxradius)
+		m.drawCircle(m.rand.intRange(r, maxx-r), m.rand.intRange(r, maxy-r), r, uint8(colorIdx))
 	}
 	return nil
 }
@@ -186,28 +173,15 @@ func (m *ItemDigit) fillWithCircles(n, maxradius int) error {
 func (m *ItemDigit) strikeThrough() error {
 	maxx := m.Bounds().Max.X
 	maxy := m.Bounds().Max.Y
-	y, err := randIntRange(maxy/3, maxy-maxy/3)
-	if err != nil {
-		return err
-	}
-	amplitude, err := randFloat64Range(5, 20)
-	if err != nil {
-		return err
-	}
-	period, err := randFloat64Range(80, 180)
-	if err != nil {
-		return err
-	}
+	y := m.rand.intRange(maxy/3, maxy-maxy/3)
+	amplitude := m.rand.float64Range(5, 20)
+	period := m.rand.float64Range(80, 180)
 	dx := 2.0 * math.Pi / period
 	for x := 0; x < maxx; x++ {
 		xo := amplitude * math.Cos(float64(y)*dx)
 		yo := amplitude * math.Sin(float64(x)*dx)
 		for yn := 0; yn < m.dotSize; yn++ {
-			rN, err := rand.Int(rand.Reader, big.NewInt(int64(m.dotSize)))
-			if err != nil {
-				return err
-			}
-			r := int(rN.Int64())
+			r := m.rand.intN(m.dotSize)
 			m.drawCircle(x+int(xo), y+int(yo)+(yn*m.dotSize), r/2, 1)
 		}
 	}
@@ -216,17 +190,10 @@ func (m *ItemDigit) strikeThrough() error {
 
 // draw digit
 func (m *ItemDigit) drawDigit(digit []byte, x, y int) error {
-	skf, err := randFloat64Range(-m.maxSkew, m.maxSkew)
-	if err != nil {
-		return err
-	}
+	skf := m.rand.float64Range(-m.maxSkew, m.maxSkew)
 	xs := float64(x)
 	r := m.dotSize / 2
-	ySum, err := randIntRange(-r, r)
-	if err != nil {
-		return err
-	}
-	y += ySum
+	y += m.rand.intRange(-r, r)
 	for yo := 0; yo < digitFontHeight; yo++ {
 		for xo := 0; xo < digitFontWidth; xo++ {
 			if digit[yo*digitFontWidth+xo] != digitFontBlackChar {
@@ -258,24 +225,19 @@ func (m *ItemDigit) distort(amplude float64, period float64) {
 	m.Paletted = newm
 }
 
-func randomBrightness(c color.RGBA, max uint8) (color.RGBA, error) {
+func randomBrightness(r randGen, c color.RGBA, max uint8) color.RGBA {
 	minc := min3(c.R, c.G, c.B)
 	maxc := max3(c.R, c.G, c.B)
 	if maxc > max {
-		return c, nil
-	}
-	// n := rand.Intn(int(max-maxc)) - int(minc)
-	nN, err := rand.Int(rand.Reader, big.NewInt(int64(int(max-maxc)+1+int(minc))))
-	if err != nil {
-		return color.RGBA{}, err
+		return c
 	}
-	n := int(nN.Int64()) - int(minc)
+	n := r.IntN(int(max-maxc)+1+int(minc)) - int(minc)
 	return color.RGBA{
 		uint8(int(c.R) + n),
 		uint8(int(c.G) + n),
 		uint8(int(c.B) + n),
 		uint8(c.A),
-	}, nil
+	}
 }
 
 func min3(x, y, z uint8) (m uint8) {
@@ -301,22 +263,38 @@ func max3(x, y, z uint8) (m uint8) {
 }
 
 // EncodeBinary encodes an image to PNG and returns a byte slice.
-func (m *ItemDigit) EncodeBinary() []byte {
-	var buf bytes.Buffer
-	if err := png.Encode(&buf, m.Paletted); err != nil {
-		panic(err.Error())
-	}
-	return buf.Bytes()
+func (m *ItemDigit) EncodeBinary() ([]byte, error) {
+	return encodeBinary(m)
 }
 
 // WriteTo writes captcha character in png format into the given io.Writer, and
 // returns the number of bytes written and an error if any.
 func (m *ItemDigit) WriteTo(w io.Writer) (int64, error) {
-	n, err := w.Write(m.EncodeBinary())
-	return int64(n), err
+	return writePNG(w, m.Paletted)
+}
+
+// WriteDataURI writes the image into the given io.Writer as a base64 data
+// URI, and returns the number of bytes written and an error if any.
+func (m *ItemDigit) WriteDataURI(w io.Writer) (int64, error) {
+	return writeDataURI(w, MimeTypeImage, m)
+}
+
+// ContentType returns the MIME type of the PNG image.
+func (m *ItemDigit) ContentType() string {
+	return MimeTypeImage
+}
+
+// Width returns the width of the image in pixels.
+func (m *ItemDigit) Width() int {
+	return m.Bounds().Dx()
+}
+
+// Height returns the height of the image in pixels.
+func (m *ItemDigit) Height() int {
+	return m.Bounds().Dy()
 }
 
 // EncodeB64string encodes an image to base64 string
-func (m *ItemDigit) EncodeB64string() string {
-	return fmt.Sprintf("data:%s;base64,%s", MimeTypeImage, base64.StdEncoding.EncodeToString(m.EncodeBinary()))
+func (m *ItemDigit) EncodeB64string() (string, error) {
+	return encodeDataURI(MimeTypeImage, m)
 }
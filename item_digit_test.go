@@ -2,6 +2,7 @@ package base64Captcha
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 )
 
@@ -51,8 +52,8 @@ func TestItemDigit_EncodeBinary(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.m.EncodeBinary(); len(got) == 0 {
-				t.Errorf("ItemDigit.EncodeBinary() = %v, want %v", got, tt.want)
+			if got, err := tt.m.EncodeBinary(); err != nil || len(got) == 0 {
+				t.Errorf("ItemDigit.EncodeBinary() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
@@ -101,8 +102,8 @@ func TestItemDigit_EncodeB64string(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			if got := tt.m.EncodeB64string(); got == tt.want {
-				t.Errorf("ItemDigit.EncodeB64string() = %v, want %v", got, tt.want)
+			if got, err := tt.m.EncodeB64string(); err != nil || got == tt.want {
+				t.Errorf("ItemDigit.EncodeB64string() = %v, %v, want %v", got, err, tt.want)
 			}
 		})
 	}
@@ -110,7 +111,34 @@ func TestItemDigit_EncodeB64string(t *testing.T) {
 
 func TestItemDigit_DotCountZero(t *testing.T) {
 	_, err := NewItemDigit(80, 300, 0, 0.25)
-	if err == nil {
-		t.Errorf("NewItemDigit() expected error for dotCount zero, got nil")
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("NewItemDigit() error for dotCount zero = %v, want ErrInvalidConfig", err)
+	}
+}
+
+func TestItemDigit_metadata(t *testing.T) {
+	item, err := NewItemDigit(240, 80, 20, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var img ImageItem = item
+	if img.ContentType() != MimeTypeImage || img.Width() != 240 || img.Height() != 80 {
+		t.Errorf("item = %s %dx%d", img.ContentType(), img.Width(), img.Height())
+	}
+}
+
+func TestItemDigit_WriteDataURI(t *testing.T) {
+	item, err := NewItemDigit(120, 40, 20, 0.7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b64s, err := item.EncodeB64string()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	n, err := item.WriteDataURI(&buf)
+	if err != nil || buf.String() != b64s || n != int64(len(b64s)) {
+		t.Errorf("WriteDataURI() = %d, %v, want %.40s...", n, err, b64s)
 	}
 }
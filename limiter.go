@@ -0,0 +1,135 @@
+package base64Captcha
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// RenderLimiter bounds the number of captchas rendered at the same time, so
+// that a flood of requests cannot take every core. Renderings beyond the
+// limit wait in a queue for up to the queue timeout, then fail with
+// ErrOverloaded.
+type RenderLimiter struct {
+	sem          chan struct{}
+	queueTimeout time.Duration
+}
+
+// NewRenderLimiter creates a limiter allowing maxConcurrent renderings at
+// the same time. A rendering waits at most queueTimeout for its turn, a
+// zero timeout fails immediately when the limit is reached.
+func NewRenderLimiter(maxConcurrent int, queueTimeout time.Duration) *RenderLimiter {
+	if maxConcurrent < 1 {
+		maxConcurrent = 1
+	}
+	return &RenderLimiter{sem: make(chan struct{}, maxConcurrent), queueTimeout: queueTimeout}
+}
+
+// acquire waits for a rendering slot. It returns ErrOverloaded when the
+// queue timeout elapses, or the error of ctx when it is done first.
+func (l *RenderLimiter) acquire(ctx context.Context) error {
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	default:
+	}
+	if l.queueTimeout <= 0 {
+		return ErrOverloaded
+	}
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+	select {
+	case l.sem <- struct{}{}:
+		return nil
+	case <-timer.C:
+		return ErrOverloaded
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release frees a rendering slot.
+func (l *RenderLimiter) release() {
+	<-l.sem
+}
+
+// clientKey is the context key of the client key.
+type clientKey struct{}
+
+// ContextWithClientKey returns a context carrying the key identifying the
+// client, such as its IP address, for the RateLimiter of a Captcha.
+func ContextWithClientKey(ctx context.Context, key string) context.Context {
+	return context.WithValue(ctx, clientKey{}, key)
+}
+
+// clientKeyFrom returns the client key carried by ctx.
+func clientKeyFrom(ctx context.Context) string {
+	key, _ := ctx.Value(clientKey{}).(string)
+	return key
+}
+
+// rateSweepInterval is how often idle buckets are dropped.
+const rateSweepInterval = time.Minute
+
+// RateLimiter is a token bucket rate limiter keyed by client. Every client
+// may generate burst captchas at once, then rate captchas per second.
+type RateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	nextSweep time.Time
+}
+
+// tokenBucket is the bucket of a client.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewRateLimiter creates a rate limiter refilling rate tokens per second, up
+// to burst tokens.
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	return &RateLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// Allow takes a token from the bucket of key and reports whether there was
+// one.
+func (l *RateLimiter) Allow(key string) bool {
+	return l.allow(key, time.Now())
+}
+
+func (l *RateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if now.After(l.nextSweep) {
+		for k, b := range l.buckets {
+			if l.refill(b, now) >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+		l.nextSweep = now.Add(rateSweepInterval)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = l.refill(b, now)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// refill returns the tokens of the bucket at now.
+func (l *RateLimiter) refill(b *tokenBucket, now time.Time) float64 {
+	tokens := b.tokens + now.Sub(b.last).Seconds()*l.rate
+	if tokens > l.burst {
+		tokens = l.burst
+	}
+	return tokens
+}
@@ -0,0 +1,114 @@
+package base64Captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// blockingDriver is a Driver whose DrawCaptcha waits until release is closed.
+type blockingDriver struct {
+	Driver
+	drawing chan struct{}
+	release chan struct{}
+}
+
+func (d blockingDriver) DrawCaptcha(content string) (Item, error) {
+	d.drawing <- struct{}{}
+	<-d.release
+	return d.Driver.DrawCaptcha(content)
+}
+
+func TestRenderLimiter(t *testing.T) {
+	l := NewRenderLimiter(1, 10*time.Millisecond)
+	ctx := context.Background()
+	if err := l.acquire(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.acquire(ctx); err != ErrOverloaded {
+		t.Errorf("acquire() = %v, want %v", err, ErrOverloaded)
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	l.queueTimeout = time.Hour
+	if err := l.acquire(canceled); err != context.Canceled {
+		t.Errorf("acquire() = %v, want %v", err, context.Canceled)
+	}
+
+	done := make(chan error)
+	go func() { done <- l.acquire(ctx) }()
+	l.release()
+	if err := <-done; err != nil {
+		t.Errorf("queued acquire() = %v", err)
+	}
+
+	l = NewRenderLimiter(1, 0)
+	_ = l.acquire(ctx)
+	if err := l.acquire(ctx); err != ErrOverloaded {
+		t.Errorf("acquire() without queue = %v, want %v", err, ErrOverloaded)
+	}
+}
+
+func TestCaptcha_RenderLimiter(t *testing.T) {
+	d := blockingDriver{DefaultDriverDigit, make(chan struct{}), make(chan struct{})}
+	c := NewCaptcha(d, NewMemoryStore(10, time.Hour))
+	c.RenderLimiter = NewRenderLimiter(1, 10*time.Millisecond)
+
+	done := make(chan error)
+	go func() {
+		_, _, _, err := c.Generate()
+		done <- err
+	}()
+	<-d.drawing
+	if _, _, _, err := c.Generate(); err != ErrOverloaded {
+		t.Errorf("Generate() = %v, want %v", err, ErrOverloaded)
+	}
+	close(d.release)
+	if err := <-done; err != nil {
+		t.Errorf("Generate() = %v", err)
+	}
+	go func() { <-d.drawing }()
+	if _, _, _, err := c.Generate(); err != nil {
+		t.Errorf("Generate() after release = %v", err)
+	}
+}
+
+func TestRateLimiter(t *testing.T) {
+	l := NewRateLimiter(1, 2)
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("a", now); got != want {
+			t.Errorf("allow() #%d = %v, want %v", i, got, want)
+		}
+	}
+	if !l.allow("b", now) {
+		t.Error("keys share a bucket")
+	}
+	if !l.allow("a", now.Add(time.Second)) || l.allow("a", now.Add(time.Second)) {
+		t.Error("the bucket is not refilled at the rate")
+	}
+
+	// Full buckets are dropped by the sweep.
+	l.allow("c", now.Add(time.Hour))
+	if _, ok := l.buckets["a"]; ok {
+		t.Error("idle bucket was not dropped")
+	}
+}
+
+func TestCaptcha_RateLimiter(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, time.Hour))
+	c.RateLimiter = NewRateLimiter(0.001, 1)
+	ctx := ContextWithClientKey(context.Background(), "10.0.0.1")
+	if _, _, _, err := c.GenerateContext(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, _, err := c.GenerateContext(ctx); err != ErrRateLimited {
+		t.Errorf("GenerateContext() = %v, want %v", err, ErrRateLimited)
+	}
+	if _, _, _, err := c.GenerateContext(ContextWithClientKey(context.Background(), "10.0.0.2")); err != nil {
+		t.Errorf("GenerateContext() of another client = %v", err)
+	}
+	if _, _, _, err := c.Generate(); err != nil {
+		t.Errorf("Generate() without client key = %v", err)
+	}
+}
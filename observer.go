@@ -0,0 +1,174 @@
+package base64Captcha
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync/atomic"
+	"time"
+)
+
+// Observer receives events of a Captcha, to collect metrics for example.
+// Its methods are called synchronously and must be safe for concurrent use.
+type Observer interface {
+	// Generated is called after a captcha was generated or reloaded.
+	Generated(e GenerateEvent)
+	// Verified is called after a captcha was verified.
+	Verified(e VerifyEvent)
+	// LateSubmission is called when a captcha is submitted after it
+	// expired, along with Verified.
+	LateSubmission(e LateSubmissionEvent)
+	// Expired is called when the store deletes a captcha which expired
+	// without being used, if the store implements ExpireNotifier. It is
+	// called by the goroutine collecting the store. A store reports to the
+	// observer of the last Captcha which generated with it, Captchas
+	// sharing a store should share their observer.
+	Expired(e ExpireEvent)
+	// StoreError is called when the store failed.
+	StoreError(e StoreErrorEvent)
+}
+
+// GenerateEvent describes a generated captcha.
+type GenerateEvent struct {
+	// Driver is the type name of the driver, such as "DriverDigit".
+	Driver string
+	// DrawDuration is the time taken by DrawCaptcha.
+	DrawDuration time.Duration
+	// Duration is the time taken by the whole generation.
+	Duration time.Duration
+}
+
+// VerifyEvent describes a verification.
+type VerifyEvent struct {
+	// Driver is the type name of the driver the captcha was generated
+	// with, as saved in its Record. It is empty if the store does not keep
+	// it, or has no record of the captcha.
+	Driver string
+	// Outcome is nil on a match, or the verification outcome, such as
+	// ErrMismatch. Store failures are reported by StoreError instead.
+	Outcome  error
+	Duration time.Duration
+}
+
+// LateSubmissionEvent describes a captcha submitted after it expired.
+type LateSubmissionEvent struct {
+	Driver string
+}
+
+// ExpireEvent describes a captcha deleted after it expired unused.
+type ExpireEvent struct {
+	// Driver is the type name of the driver the captcha was generated
+	// with, as saved in its Record.
+	Driver string
+}
+
+// StoreErrorEvent describes a store failure.
+type StoreErrorEvent struct {
+	// Driver is empty for passes, and for verifications which the store
+	// failed before reading the record.
+	Driver string
+	// Op is the failed operation: "generate", "reload", "verify" or "pass".
+	Op  string
+	Err error
+}
+
+// driverName returns the type name of a driver, or of the driver of a Pool.
+func driverName(d Driver) string {
+	if p, ok := d.(*Pool); ok {
+		d = p.Driver
+	}
+	t := reflect.TypeOf(d)
+	if t == nil {
+		return ""
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
+}
+
+// observeGenerated reports a captcha generated with driver to the observer,
+// if any.
+func (c *Captcha) observeGenerated(driver Driver, start time.Time, draw time.Duration) {
+	if c.Observer != nil {
+		c.Observer.Generated(GenerateEvent{Driver: driverName(driver), DrawDuration: draw, Duration: time.Since(start)})
+		c.observeExpired()
+	}
+}
+
+// observeExpired registers the observer with the store, if it reports the
+// captchas which expire unused.
+func (c *Captcha) observeExpired() {
+	var store interface{} = c.StoreContext
+	if store == nil {
+		store = c.Store
+	}
+	if n, ok := store.(ExpireNotifier); ok {
+		n.OnExpire(c.Observer.Expired)
+	}
+}
+
+// observeVerified reports the outcome of the verification of a captcha
+// generated with the named driver to the observer, if any. A store failure
+// is reported as such.
+func (c *Captcha) observeVerified(name string, start time.Time, err error) {
+	if c.Observer == nil {
+		return
+	}
+	if err != nil && !isVerifyOutcome(err) {
+		c.observeStoreError(name, "verify", err)
+		return
+	}
+	c.Observer.Verified(VerifyEvent{Driver: name, Outcome: err, Duration: time.Since(start)})
+	if errors.Is(err, ErrExpired) {
+		c.Observer.LateSubmission(LateSubmissionEvent{Driver: name})
+	}
+}
+
+// observeStoreError reports a store failure with the named driver to the
+// observer, if any.
+func (c *Captcha) observeStoreError(name string, op string, err error) {
+	if c.Observer != nil {
+		c.Observer.StoreError(StoreErrorEvent{Driver: name, Op: op, Err: err})
+	}
+}
+
+// recordDriverKey is the context key of the name of the driver of the
+// record verified by a store.
+type recordDriverKey struct{}
+
+// withRecordDriver returns a context in which the store reports the driver
+// of the record it verifies into name.
+func withRecordDriver(ctx context.Context, name *string) context.Context {
+	return context.WithValue(ctx, recordDriverKey{}, name)
+}
+
+// reportRecordDriver is called by the stores with the driver of the record
+// they verify.
+func reportRecordDriver(ctx context.Context, driver string) {
+	if name, ok := ctx.Value(recordDriverKey{}).(*string); ok {
+		*name = driver
+	}
+}
+
+// expireHook implements ExpireNotifier for the stores.
+type expireHook struct {
+	fn atomic.Pointer[func(ExpireEvent)]
+}
+
+// OnExpire implements ExpireNotifier.
+func (h *expireHook) OnExpire(fn func(e ExpireEvent)) {
+	if fn == nil {
+		h.fn.Store(nil)
+		return
+	}
+	h.fn.Store(&fn)
+}
+
+// expireFunc returns the function registered with OnExpire, or nil.
+func (h *expireHook) expireFunc() func(ExpireEvent) {
+	if fn := h.fn.Load(); fn != nil {
+		return *fn
+	}
+	return nil
+}
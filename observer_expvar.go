@@ -0,0 +1,176 @@
+package base64Captcha
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// latencyBuckets are the upper bounds of the latency histogram buckets.
+var latencyBuckets = [...]time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+}
+
+// ExpvarObserver is an Observer publishing its metrics with the expvar
+// package, so they are served on /debug/vars. The published map holds one
+// map per driver with these variables:
+//
+//	generated          captchas generated
+//	verified           verifications by outcome: match, mismatch, expired...
+//	late_submissions   captchas submitted after they expired
+//	expired            captchas deleted by the store after they expired unused
+//	store_errors       store failures by operation
+//	draw_latency       histogram of DrawCaptcha durations
+//	generate_latency   histogram of whole generation durations
+//	verify_latency     histogram of verification durations
+//
+// Histograms hold cumulative bucket counts, the count and the sum of the
+// observed durations in milliseconds.
+type ExpvarObserver struct {
+	vars    *expvar.Map
+	mu      sync.Mutex
+	drivers map[string]*expvarDriverVars
+}
+
+// expvarDriverVars are the variables of one driver.
+type expvarDriverVars struct {
+	generated       expvar.Int
+	verified        expvar.Map
+	lateSubmissions expvar.Int
+	expired         expvar.Int
+	storeErrors     expvar.Map
+	drawLatency     latencyHistogram
+	generateLatency latencyHistogram
+	verifyLatency   latencyHistogram
+}
+
+// NewExpvarObserver creates an observer publishing its metrics under name.
+// Like expvar.Publish, it panics if the name is already in use.
+func NewExpvarObserver(name string) *ExpvarObserver {
+	return &ExpvarObserver{vars: expvar.NewMap(name), drivers: make(map[string]*expvarDriverVars)}
+}
+
+// driver returns the variables of a driver, publishing them on first use.
+func (o *ExpvarObserver) driver(name string) *expvarDriverVars {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if v, ok := o.drivers[name]; ok {
+		return v
+	}
+	v := new(expvarDriverVars)
+	m := new(expvar.Map).Init()
+	m.Set("generated", &v.generated)
+	m.Set("verified", v.verified.Init())
+	m.Set("late_submissions", &v.lateSubmissions)
+	m.Set("expired", &v.expired)
+	m.Set("store_errors", v.storeErrors.Init())
+	m.Set("draw_latency", &v.drawLatency)
+	m.Set("generate_latency", &v.generateLatency)
+	m.Set("verify_latency", &v.verifyLatency)
+	o.vars.Set(name, m)
+	o.drivers[name] = v
+	return v
+}
+
+// Generated implements Observer.
+func (o *ExpvarObserver) Generated(e GenerateEvent) {
+	v := o.driver(e.Driver)
+	v.generated.Add(1)
+	v.drawLatency.observe(e.DrawDuration)
+	v.generateLatency.observe(e.Duration)
+}
+
+// Verified implements Observer.
+func (o *ExpvarObserver) Verified(e VerifyEvent) {
+	v := o.driver(e.Driver)
+	v.verified.Add(outcomeName(e.Outcome), 1)
+	v.verifyLatency.observe(e.Duration)
+}
+
+// LateSubmission implements Observer.
+func (o *ExpvarObserver) LateSubmission(e LateSubmissionEvent) {
+	o.driver(e.Driver).lateSubmissions.Add(1)
+}
+
+// Expired implements Observer.
+func (o *ExpvarObserver) Expired(e ExpireEvent) {
+	o.driver(e.Driver).expired.Add(1)
+}
+
+// StoreError implements Observer.
+func (o *ExpvarObserver) StoreError(e StoreErrorEvent) {
+	o.driver(e.Driver).storeErrors.Add(e.Op, 1)
+}
+
+// outcomeName returns the metric name of a verification outcome.
+func outcomeName(err error) string {
+	switch {
+	case err == nil:
+		return "match"
+	case errors.Is(err, ErrNotFound):
+		return "not_found"
+	case errors.Is(err, ErrExpired):
+		return "expired"
+	case errors.Is(err, ErrMismatch):
+		return "mismatch"
+	case errors.Is(err, ErrAlreadyUsed):
+		return "already_used"
+	case errors.Is(err, ErrTooManyAttempts):
+		return "too_many_attempts"
+	case errors.Is(err, ErrBindingMismatch):
+		return "binding_mismatch"
+	case errors.Is(err, ErrInvalidToken):
+		return "invalid_token"
+	}
+	return "other"
+}
+
+// latencyHistogram is an expvar.Var counting durations in latencyBuckets.
+type latencyHistogram struct {
+	buckets [len(latencyBuckets) + 1]atomic.Int64 // the last one is +Inf
+	count   atomic.Int64
+	sum     atomic.Int64
+}
+
+// observe adds a duration to the histogram.
+func (h *latencyHistogram) observe(d time.Duration) {
+	i := 0
+	for i < len(latencyBuckets) && d > latencyBuckets[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+	h.count.Add(1)
+	h.sum.Add(int64(d))
+}
+
+// String implements expvar.Var.
+func (h *latencyHistogram) String() string {
+	buckets := make(map[string]int64, len(h.buckets))
+	var n int64
+	for i := range h.buckets {
+		n += h.buckets[i].Load()
+		if i < len(latencyBuckets) {
+			buckets[latencyBuckets[i].String()] = n
+		} else {
+			buckets["+Inf"] = n
+		}
+	}
+	b, _ := json.Marshal(struct {
+		Buckets map[string]int64 `json:"buckets"`
+		Count   int64            `json:"count"`
+		SumMs   float64          `json:"sum_ms"`
+	}{buckets, h.count.Load(), float64(h.sum.Load()) / float64(time.Millisecond)})
+	return string(b)
+}
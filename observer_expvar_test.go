@@ -0,0 +1,54 @@
+package base64Captcha
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+	"time"
+)
+
+func TestExpvarObserver(t *testing.T) {
+	o := NewExpvarObserver("captcha_test_observer")
+	o.Generated(GenerateEvent{Driver: "DriverDigit", DrawDuration: 3 * time.Millisecond, Duration: 4 * time.Millisecond})
+	o.Verified(VerifyEvent{Driver: "DriverDigit", Duration: time.Millisecond})
+	o.Verified(VerifyEvent{Driver: "DriverDigit", Outcome: ErrExpired, Duration: time.Millisecond})
+	o.LateSubmission(LateSubmissionEvent{Driver: "DriverDigit"})
+	o.Expired(ExpireEvent{Driver: "DriverDigit"})
+	o.StoreError(StoreErrorEvent{Driver: "DriverAudio", Op: "verify", Err: errStoreDown})
+
+	var got map[string]struct {
+		Generated   int64            `json:"generated"`
+		Verified    map[string]int64 `json:"verified"`
+		Late        int64            `json:"late_submissions"`
+		Expired     int64            `json:"expired"`
+		StoreErrors map[string]int64 `json:"store_errors"`
+		DrawLatency struct {
+			Buckets map[string]int64 `json:"buckets"`
+			Count   int64            `json:"count"`
+			SumMs   float64          `json:"sum_ms"`
+		} `json:"draw_latency"`
+	}
+	if err := json.Unmarshal([]byte(expvar.Get("captcha_test_observer").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+	digit := got["DriverDigit"]
+	if digit.Generated != 1 || digit.Late != 1 || digit.Expired != 1 || digit.Verified["match"] != 1 || digit.Verified["expired"] != 1 {
+		t.Errorf("DriverDigit = %+v", digit)
+	}
+	h := digit.DrawLatency
+	if h.Count != 1 || h.SumMs != 3 || h.Buckets["1ms"] != 0 || h.Buckets["5ms"] != 1 || h.Buckets["+Inf"] != 1 {
+		t.Errorf("draw_latency = %+v", h)
+	}
+	if got["DriverAudio"].StoreErrors["verify"] != 1 {
+		t.Errorf("DriverAudio = %+v", got["DriverAudio"])
+	}
+}
+
+func Test_outcomeName(t *testing.T) {
+	if got := outcomeName(nil); got != "match" {
+		t.Errorf("outcomeName(nil) = %q", got)
+	}
+	if got := outcomeName(ErrTooManyAttempts); got != "too_many_attempts" {
+		t.Errorf("outcomeName() = %q", got)
+	}
+}
@@ -0,0 +1,139 @@
+package base64Captcha
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingObserver keeps the events it receives.
+type recordingObserver struct {
+	mu        sync.Mutex
+	generated []GenerateEvent
+	verified  []VerifyEvent
+	late      []LateSubmissionEvent
+	expired   []ExpireEvent
+	errors    []StoreErrorEvent
+}
+
+func (o *recordingObserver) Generated(e GenerateEvent) {
+	o.mu.Lock()
+	o.generated = append(o.generated, e)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) Verified(e VerifyEvent) {
+	o.mu.Lock()
+	o.verified = append(o.verified, e)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) LateSubmission(e LateSubmissionEvent) {
+	o.mu.Lock()
+	o.late = append(o.late, e)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) Expired(e ExpireEvent) {
+	o.mu.Lock()
+	o.expired = append(o.expired, e)
+	o.mu.Unlock()
+}
+
+func (o *recordingObserver) StoreError(e StoreErrorEvent) {
+	o.mu.Lock()
+	o.errors = append(o.errors, e)
+	o.mu.Unlock()
+}
+
+func TestCaptcha_Observer(t *testing.T) {
+	o := new(recordingObserver)
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, 50*time.Millisecond))
+	c.Observer = o
+
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(o.generated) != 1 || o.generated[0].Driver != "DriverDigit" || o.generated[0].DrawDuration <= 0 {
+		t.Errorf("generated events = %+v", o.generated)
+	}
+	c.Verify(id, answer+"0", false)
+	c.Verify(id, answer, true)
+	if len(o.verified) != 2 || o.verified[0].Outcome != ErrMismatch || o.verified[1].Outcome != nil {
+		t.Errorf("verified events = %+v", o.verified)
+	}
+
+	id, _, answer, _ = c.Generate()
+	time.Sleep(100 * time.Millisecond)
+	c.Verify(id, answer, true)
+	if len(o.late) != 1 || o.late[0].Driver != "DriverDigit" {
+		t.Errorf("late submission events = %+v", o.late)
+	}
+
+	c = NewCaptchaContext(DefaultDriverDigit, downStore{})
+	c.Observer = o
+	_, _, _, _ = c.Generate()
+	c.Verify("id", "1234", true)
+	if len(o.errors) != 2 || o.errors[0].Op != "generate" || o.errors[1].Op != "verify" || o.errors[1].Err != errStoreDown {
+		t.Errorf("store error events = %+v", o.errors)
+	}
+	if len(o.verified) != 3 {
+		t.Errorf("a store failure was reported as a verification: %+v", o.verified)
+	}
+}
+
+func TestCaptcha_Observer_difficulty(t *testing.T) {
+	o := new(recordingObserver)
+	hard := NewDriverString(80, 240, 0, 0, 6, TxtAlphabet, nil, nil, nil)
+	c := NewCaptcha(DefaultDriverDigit, NewMemoryStore(10, time.Hour))
+	c.Observer = o
+	c.Difficulty = TieredDifficulty{{Driver: DefaultDriverDigit}, {MinScore: 0.5, Driver: hard}}
+	ctx := ContextWithRiskScore(context.Background(), 0.9)
+
+	id, _, answer, err := c.GenerateContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The risk score is gone by the time the answer comes back, the
+	// label has to come from the record.
+	c.CheckContext(context.Background(), id, answer, true)
+	if len(o.generated) != 1 || o.generated[0].Driver != "DriverString" {
+		t.Errorf("generated events = %+v", o.generated)
+	}
+	if len(o.verified) != 1 || o.verified[0].Driver != "DriverString" {
+		t.Errorf("verified events = %+v", o.verified)
+	}
+
+	c.StoreContext = downStore{}
+	c.GenerateContext(ctx)
+	c.CheckContext(ctx, id, answer, true)
+	if len(o.errors) != 2 || o.errors[0].Driver != "DriverString" || o.errors[1].Driver != "" {
+		t.Errorf("store error events = %+v", o.errors)
+	}
+}
+
+func TestCaptcha_Observer_expired(t *testing.T) {
+	o := new(recordingObserver)
+	store := NewMemoryStore(100, 50*time.Millisecond).(*memoryStore)
+	c := NewCaptcha(DefaultDriverDigit, store)
+	c.Observer = o
+	c.Generate()
+	id, _, answer, _ := c.Generate()
+	c.Verify(id, answer, true)
+	time.Sleep(100 * time.Millisecond)
+	store.collect()
+	if len(o.expired) != 1 || o.expired[0].Driver != "DriverDigit" {
+		t.Errorf("expired events = %+v", o.expired)
+	}
+}
+
+func Test_driverName(t *testing.T) {
+	if got := driverName(DefaultDriverDigit); got != "DriverDigit" {
+		t.Errorf("driverName() = %q", got)
+	}
+	if got := driverName(nil); got != "" {
+		t.Errorf("driverName(nil) = %q", got)
+	}
+}
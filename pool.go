@@ -0,0 +1,176 @@
+package base64Captcha
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// poolRetryDelay is how long a pool worker waits after a failed rendering.
+var poolRetryDelay = 100 * time.Millisecond
+
+// Pool is a Driver which renders captchas ahead of time with background
+// workers, so that Captcha.Generate does not pay for the rendering. Use it
+// as the driver of a Captcha:
+//
+//	pool := NewPool(driver, 256, 2)
+//	pool.Start()
+//	defer pool.Stop()
+//	c := NewCaptcha(pool, store)
+//
+// When the buffer is empty the captcha is rendered inline and counted as a
+// miss. A pool which is not started always misses.
+type Pool struct {
+	// Driver renders the captchas.
+	Driver Driver
+
+	workers int
+	buf     chan pooledCaptcha
+	mu      sync.Mutex
+	stop    chan struct{}
+	wg      sync.WaitGroup
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// pooledCaptcha is a captcha rendered ahead of time.
+type pooledCaptcha struct {
+	answer string
+	item   Item
+	b64s   string
+	// draw is the time taken by DrawCaptcha.
+	draw time.Duration
+}
+
+// PoolStats are statistics of a Pool.
+type PoolStats struct {
+	// Depth is the number of captchas in the buffer.
+	Depth int
+	// Capacity is the size of the buffer.
+	Capacity int
+	// Hits and Misses count the captchas taken from the buffer and the
+	// ones rendered inline because it was empty.
+	Hits   uint64
+	Misses uint64
+	// Errors counts the failed renderings of the workers.
+	Errors uint64
+}
+
+// NewPool creates a pool buffering up to size captchas of driver, rendered
+// by the given number of workers once the pool is started.
+func NewPool(driver Driver, size, workers int) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	return &Pool{Driver: driver, workers: workers, buf: make(chan pooledCaptcha, size)}
+}
+
+// Start starts the workers filling the buffer. It does nothing if the pool
+// is running already.
+func (p *Pool) Start() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop != nil {
+		return
+	}
+	p.stop = make(chan struct{})
+	for i := 0; i < p.workers; i++ {
+		p.wg.Add(1)
+		go p.work(p.stop)
+	}
+}
+
+// Stop stops the workers and waits for them to return. Captchas left in the
+// buffer are still handed out.
+func (p *Pool) Stop() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stop == nil {
+		return
+	}
+	close(p.stop)
+	p.wg.Wait()
+	p.stop = nil
+}
+
+// Stats returns statistics of the pool.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Depth:    len(p.buf),
+		Capacity: cap(p.buf),
+		Hits:     p.hits.Load(),
+		Misses:   p.misses.Load(),
+		Errors:   p.errors.Load(),
+	}
+}
+
+// work renders captchas into the buffer until stop is closed.
+func (p *Pool) work(stop chan struct{}) {
+	defer p.wg.Done()
+	for {
+		pc, err := p.render()
+		if err != nil {
+			p.errors.Add(1)
+			select {
+			case <-time.After(poolRetryDelay):
+				continue
+			case <-stop:
+				return
+			}
+		}
+		select {
+		case p.buf <- pc:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// render renders a captcha with the driver of the pool.
+func (p *Pool) render() (pooledCaptcha, error) {
+	_, content, answer, err := p.Driver.GenerateIdQuestionAnswer()
+	if err != nil {
+		return pooledCaptcha{}, err
+	}
+	start := time.Now()
+	item, err := p.Driver.DrawCaptcha(content)
+	if err != nil {
+		return pooledCaptcha{}, err
+	}
+	draw := time.Since(start)
+	b64s, err := item.EncodeB64string()
+	if err != nil {
+		return pooledCaptcha{}, err
+	}
+	return pooledCaptcha{answer: answer, item: item, b64s: b64s, draw: draw}, nil
+}
+
+// take returns a captcha from the buffer, or false if it is empty.
+func (p *Pool) take() (pooledCaptcha, bool) {
+	select {
+	case pc := <-p.buf:
+		p.hits.Add(1)
+		return pc, true
+	default:
+		p.misses.Add(1)
+		return pooledCaptcha{}, false
+	}
+}
+
+// GenerateIdQuestionAnswer creates id,content and answer with the driver of the pool
+func (p *Pool) GenerateIdQuestionAnswer() (id, q, a string, err error) {
+	return p.Driver.GenerateIdQuestionAnswer()
+}
+
+// GenerateSpecificIdQuestionAnswer creates content and answer for the given id
+func (p *Pool) GenerateSpecificIdQuestionAnswer(mId string) (id, q, a string, err error) {
+	_, q, a, err = p.Driver.GenerateIdQuestionAnswer()
+	return mId, q, a, err
+}
+
+// DrawCaptcha draws the item with the driver of the pool
+func (p *Pool) DrawCaptcha(content string) (item Item, err error) {
+	return p.Driver.DrawCaptcha(content)
+}
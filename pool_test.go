@@ -0,0 +1,93 @@
+package base64Captcha
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// failingDriver is a Driver which cannot render captchas.
+type failingDriver struct{ Driver }
+
+func (failingDriver) DrawCaptcha(content string) (Item, error) {
+	return nil, errors.New("no fonts")
+}
+
+// waitDepth waits until the buffer of the pool holds n captchas.
+func waitDepth(t *testing.T, p *Pool, n int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Depth < n {
+		if time.Now().After(deadline) {
+			t.Fatalf("pool depth = %d, want %d", p.Stats().Depth, n)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestPool(t *testing.T) {
+	p := NewPool(DefaultDriverDigit, 4, 2)
+	c := NewCaptcha(p, NewMemoryStore(10, time.Hour))
+
+	// A pool which is not started renders inline.
+	id, b64s, answer, err := c.Generate()
+	if err != nil || b64s == "" || !c.Verify(id, answer, true) {
+		t.Fatalf("Generate() = %q, %q, %v", id, answer, err)
+	}
+	if s := p.Stats(); s.Misses != 1 || s.Hits != 0 {
+		t.Errorf("Stats() = %+v, want one miss", s)
+	}
+
+	p.Start()
+	p.Start()
+	waitDepth(t, p, 4)
+	if s := p.Stats(); s.Capacity != 4 || s.Depth != 4 {
+		t.Errorf("Stats() = %+v", s)
+	}
+	id, b64s, answer, err = c.Generate()
+	if err != nil || b64s == "" {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if got := c.Store.Get(id, false); got != answer {
+		t.Errorf("stored answer = %q, want %q", got, answer)
+	}
+	if !c.Verify(id, answer, true) {
+		t.Error("pooled captcha failed")
+	}
+	if s := p.Stats(); s.Hits != 1 {
+		t.Errorf("Stats() = %+v, want one hit", s)
+	}
+
+	id, _, _, _ = c.Generate()
+	_, reloaded, err := c.Reload(id)
+	if err != nil || !c.Verify(id, reloaded, true) {
+		t.Errorf("Reload() = %q, %v", reloaded, err)
+	}
+
+	p.Stop()
+	p.Stop()
+	depth := p.Stats().Depth
+	time.Sleep(10 * time.Millisecond)
+	if p.Stats().Depth != depth {
+		t.Error("the buffer is filled after Stop")
+	}
+}
+
+func TestPool_Errors(t *testing.T) {
+	defer func(d time.Duration) { poolRetryDelay = d }(poolRetryDelay)
+	poolRetryDelay = time.Millisecond
+
+	p := NewPool(failingDriver{DefaultDriverDigit}, 4, 1)
+	p.Start()
+	defer p.Stop()
+	deadline := time.Now().Add(5 * time.Second)
+	for p.Stats().Errors < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("rendering errors are not counted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, _, _, err := NewCaptcha(p, NewMemoryStore(10, time.Hour)).Generate(); err == nil {
+		t.Error("Generate() with a failing driver succeeded")
+	}
+}
@@ -1,19 +1,97 @@
 package base64Captcha
 
 import (
-	"crypto/rand"
 	"encoding/binary"
 	"image/color"
 	"math"
-	"math/big"
 	mathrand "math/rand/v2"
 	"strings"
+
+	"github.com/golang/freetype/truetype"
 )
 
-// RandText creates random text of given size.
-func RandText(size int, sourceChars string) (string, error) {
+// RandSource is the source of randomness of drivers and items. It has the
+// method set of math/rand/v2.Source, so a seeded source such as
+// rand.NewPCG makes DrawCaptcha deterministic, for golden image tests.
+// Seeded sources are predictable and not safe for concurrent use, so they
+// must not be used to serve captchas.
+//
+// When no source is given, every rendering draws from its own ChaCha8
+// stream seeded from crypto/rand. ChaCha8 is a cryptographically strong
+// generator which buffers its output, so unlike CryptoRandSource a draw
+// does not read crypto/rand.
+type RandSource interface {
+	Uint64() uint64
+}
+
+// CryptoRandSource reads crypto/rand for every draw.
+var CryptoRandSource RandSource = cryptoRandSource{}
+
+// cryptoRandSource is a RandSource reading crypto/rand.
+type cryptoRandSource struct{}
+
+// Uint64 returns a random uint64.
+func (cryptoRandSource) Uint64() uint64 {
+	return binary.LittleEndian.Uint64(randomBytes(8))
+}
+
+// NewSeededRandSource returns a deterministic source for tests.
+func NewSeededRandSource(seed uint64) RandSource {
+	return mathrand.NewPCG(seed, seed)
+}
+
+// newChaCha8Source returns a ChaCha8 stream seeded from crypto/rand.
+func newChaCha8Source() RandSource {
+	var seed [32]byte
+	copy(seed[:], randomBytes(len(seed)))
+	return mathrand.NewChaCha8(seed)
+}
+
+// randGen draws random values from a RandSource. Its integers are uniform,
+// without modulo bias.
+type randGen struct {
+	*mathrand.Rand
+}
+
+// newRandGen returns a generator drawing from src, or from a new ChaCha8
+// stream if src is nil.
+func newRandGen(src RandSource) randGen {
+	if src == nil {
+		src = newChaCha8Source()
+	}
+	return randGen{mathrand.New(src)}
+}
+
+// intN returns a number in [0, n), or 0 if n <= 0.
+func (r randGen) intN(n int) int {
+	if n <= 0 {
+		return 0
+	}
+	return r.IntN(n)
+}
+
+// intRange returns a number in [from, to), or from if the range is empty.
+func (r randGen) intRange(from, to int) int {
+	return from + r.intN(to-from)
+}
+
+// float64Range returns a number in [from, to).
+func (r randGen) float64Range(from, to float64) float64 {
+	return r.Float64()*(to-from) + from
+}
+
+// random get random number between min and max. 生成指定大小的随机数.
+func (r randGen) random(min int64, max int64) float64 {
+	if max-min <= 0 {
+		return float64(min)
+	}
+	return float64(min) + float64(r.Int64N(max-min))
+}
+
+// text creates random text of given size.
+func (r randGen) text(size int, sourceChars string) string {
 	if sourceChars == "" || size == 0 {
-		return "", nil
+		return ""
 	}
 
 	if size >= len(sourceChars) {
@@ -21,92 +99,59 @@ func RandText(size int, sourceChars string) (string, error) {
 	}
 
 	sourceRunes := []rune(sourceChars)
-	sourceLength := len(sourceRunes)
-
 	text := make([]rune, size)
 	for i := range text {
-		nBig, err := rand.Int(rand.Reader, big.NewInt(int64(sourceLength)))
-		if err != nil {
-			return "", err
-		}
-		text[i] = sourceRunes[int(nBig.Int64())]
+		text[i] = sourceRunes[r.IntN(len(sourceRunes))]
 	}
-	return string(text), nil
+	return string(text)
 }
 
-// Random get random number between min and max. 生成指定大小的随机数.
-func random(min int64, max int64) (float64, error) {
-	if max-min <= 0 {
-		return float64(min), nil
+// digits returns length random numbers in range 0-9.
+func (r randGen) digits(length int) []byte {
+	if length == 0 {
+		return nil
 	}
-	nBig, err := rand.Int(rand.Reader, big.NewInt(max-min))
-	if err != nil {
-		return 0, err
+	b := make([]byte, length)
+	for i := range b {
+		b[i] = byte(r.IntN(10))
 	}
-	return float64(min) + float64(nBig.Int64()), nil
+	return b
 }
 
-// RandDeepColor get random deep color. 随机生成深色系.
-func RandDeepColor() (color.RGBA, error) {
-
-	randColor, err := RandColor()
-	if err != nil {
-		return color.RGBA{}, err
+// bytes returns n random bytes.
+func (r randGen) bytes(n int) []byte {
+	b := make([]byte, (n+7)/8*8)
+	for i := 0; i < len(b); i += 8 {
+		binary.LittleEndian.PutUint64(b[i:], r.Uint64())
 	}
+	return b[:n]
+}
 
-	// increase := float64(30 + rand.Intn(255))
-	increaseF, err := randFloat64Range(30, 100)
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	increase := increaseF
+// deepColor get random deep color. 随机生成深色系.
+func (r randGen) deepColor() color.RGBA {
+	randColor := r.color()
 
-	red := math.Abs(math.Min(float64(randColor.R)-increase, 255))
+	increase := r.float64Range(30, 100)
 
+	red := math.Abs(math.Min(float64(randColor.R)-increase, 255))
 	green := math.Abs(math.Min(float64(randColor.G)-increase, 255))
 	blue := math.Abs(math.Min(float64(randColor.B)-increase, 255))
 
-	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}, nil
+	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}
 }
 
-// RandLightColor get random ligth color. 随机生成浅色.
-func RandLightColor() (color.RGBA, error) {
-	// red := rand.Intn(55) + 200
-	redN, err := rand.Int(rand.Reader, big.NewInt(55))
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	red := int(redN.Int64()) + 200
-	// green := rand.Intn(55) + 200
-	greenN, err := rand.Int(rand.Reader, big.NewInt(55))
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	green := int(greenN.Int64()) + 200
-	// blue := rand.Intn(55) + 200
-	blueN, err := rand.Int(rand.Reader, big.NewInt(55))
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	blue := int(blueN.Int64()) + 200
-	//
-	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}, nil
+// lightColor get random ligth color. 随机生成浅色.
+func (r randGen) lightColor() color.RGBA {
+	red := r.IntN(55) + 200
+	green := r.IntN(55) + 200
+	blue := r.IntN(55) + 200
+	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}
 }
 
-// RandColor get random color. 生成随机颜色.
-func RandColor() (color.RGBA, error) {
-	// red := rand.Intn(255)
-	redN, err := rand.Int(rand.Reader, big.NewInt(255))
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	red := int(redN.Int64())
-	// green := rand.Intn(255)
-	greenN, err := rand.Int(rand.Reader, big.NewInt(255))
-	if err != nil {
-		return color.RGBA{}, err
-	}
-	green := int(greenN.Int64())
+// color get random color. 生成随机颜色.
+func (r randGen) color() color.RGBA {
+	red := r.IntN(255)
+	green := r.IntN(255)
 	var blue int
 	if (red + green) > 400 {
 		blue = 0
@@ -116,43 +161,51 @@ func RandColor() (color.RGBA, error) {
 	if blue > 255 {
 		blue = 255
 	}
-	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}, nil
+	return color.RGBA{R: uint8(red), G: uint8(green), B: uint8(blue), A: uint8(255)}
 }
 
-func randIntRange(from, to int) (int, error) {
-	// rand.Intn panics if n <= 0.
-	if to-from <= 0 {
-		return from, nil
-	}
-	n, err := rand.Int(rand.Reader, big.NewInt(int64(to-from)))
-	if err != nil {
-		return 0, err
+// font choose random font family.选择随机的字体
+func (r randGen) font(fonts []*truetype.Font) *truetype.Font {
+	if len(fonts) == 0 {
+		//loading default fonts
+		fonts = fontsAll
 	}
-	return int(n.Int64()) + from, nil
+	return fonts[r.IntN(len(fonts))]
 }
-func randFloat64Range(from, to float64) (float64, error) {
-	n, err := rand.Int(rand.Reader, big.NewInt(1000000))
-	if err != nil {
-		return 0, err
-	}
-	return (float64(n.Int64())/1000000)*(to-from) + from, nil
+
+// RandText creates random text of given size.
+func RandText(size int, sourceChars string) (string, error) {
+	return newRandGen(nil).text(size, sourceChars), nil
+}
+
+// Random get random number between min and max. 生成指定大小的随机数.
+func random(min int64, max int64) (float64, error) {
+	return newRandGen(nil).random(min, max), nil
 }
-func randBytes(n int) ([]byte, error) {
-	// Since we don't have a buffer for generated bytes in siprng state,
-	// we just generate enough 8-byte blocks and then cut the result to the
-	// required length. Doing it this way, we lose generated bytes, and we
-	// don't get the strictly sequential deterministic output from PRNG:
-	// calling Uint64() and then Bytes(3) produces different output than
-	// when calling them in the reverse order, but for our applications
-	// this is OK.
-	numBlocks := (n + 8 - 1) / 8
-	b := make([]byte, numBlocks*8)
-	for i := 0; i < len(b); i += 8 {
 
-		binary.LittleEndian.PutUint64(b[i:], mathrand.Uint64())
+// RandDeepColor get random deep color. 随机生成深色系.
+func RandDeepColor() (color.RGBA, error) {
+	return newRandGen(nil).deepColor(), nil
+}
 
-	}
-	return b[:n], nil
+// RandLightColor get random ligth color. 随机生成浅色.
+func RandLightColor() (color.RGBA, error) {
+	return newRandGen(nil).lightColor(), nil
+}
+
+// RandColor get random color. 生成随机颜色.
+func RandColor() (color.RGBA, error) {
+	return newRandGen(nil).color(), nil
+}
+
+func randIntRange(from, to int) (int, error) {
+	return newRandGen(nil).intRange(from, to), nil
+}
+func randFloat64Range(from, to float64) (float64, error) {
+	return newRandGen(nil).float64Range(from, to), nil
+}
+func randBytes(n int) ([]byte, error) {
+	return newRandGen(nil).bytes(n), nil
 }
 
 // RandomId returns a new random id key string.
@@ -2,6 +2,8 @@ package base64Captcha
 
 import (
 	"bytes"
+	"crypto/rand"
+	"math/big"
 	"testing"
 )
 
@@ -214,3 +216,169 @@ func TestRandBytes(t *testing.T) {
 		})
 	}
 }
+
+func TestNewSeededRandSource(t *testing.T) {
+	a := newRandGen(NewSeededRandSource(7)).bytes(32)
+	b := newRandGen(NewSeededRandSource(7)).bytes(32)
+	if !bytes.Equal(a, b) {
+		t.Errorf("same seed gave %x and %x", a, b)
+	}
+	if c := newRandGen(NewSeededRandSource(8)).bytes(32); bytes.Equal(a, c) {
+		t.Errorf("different seeds gave %x", a)
+	}
+}
+
+func TestRandGen_intN(t *testing.T) {
+	r := newRandGen(nil)
+	if got := r.intN(0); got != 0 {
+		t.Errorf("intN(0) = %d, want 0", got)
+	}
+	if got := r.intRange(5, 5); got != 5 {
+		t.Errorf("intRange(5, 5) = %d, want 5", got)
+	}
+	for i := 0; i < 100; i++ {
+		if got := r.intRange(-3, 3); got < -3 || got >= 3 {
+			t.Fatalf("intRange(-3, 3) = %d", got)
+		}
+	}
+}
+
+func TestRandSource_deterministicDrawing(t *testing.T) {
+	drivers := map[string]func(src RandSource) Driver{
+		"digit": func(src RandSource) Driver {
+			d := NewDriverDigit(80, 240, 5, 0.7, 80)
+			d.Rand = src
+			return d
+		},
+		"audio": func(src RandSource) Driver {
+			d := NewDriverAudio(4, "en")
+			d.Rand = src
+			return d
+		},
+		"string": func(src RandSource) Driver {
+			d := NewDriverString(60, 240, 10, OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, 4, TxtAlphabet, nil, nil, nil)
+			d.Rand = src
+			return d
+		},
+		"math": func(src RandSource) Driver {
+			d := NewDriverMath(60, 240, 10, OptionShowHollowLine|OptionShowSlimeLine|OptionShowSineLine, nil, nil, nil)
+			d.Rand = src
+			return d
+		},
+		"chinese": func(src RandSource) Driver {
+			d := NewDriverChinese(60, 240, 10, OptionShowSlimeLine, 2, TxtChineseCharaters, nil, nil, nil)
+			d.Rand = src
+			return d
+		},
+		"language": func(src RandSource) Driver {
+			d := NewDriverLanguage(60, 240, 10, OptionShowSineLine, 4, nil, nil, nil, "greek")
+			d.Rand = src
+			return d
+		},
+	}
+	draw := func(t *testing.T, d Driver) []byte {
+		_, q, _, err := d.GenerateIdQuestionAnswer()
+		if err != nil {
+			t.Fatal(err)
+		}
+		item, err := d.DrawCaptcha(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if _, err := item.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		return buf.Bytes()
+	}
+	for name, newDriver := range drivers {
+		t.Run(name, func(t *testing.T) {
+			a := draw(t, newDriver(NewSeededRandSource(1)))
+			b := draw(t, newDriver(NewSeededRandSource(1)))
+			if !bytes.Equal(a, b) {
+				t.Error("same seed drew different captchas")
+			}
+			if c := draw(t, newDriver(NewSeededRandSource(2))); bytes.Equal(a, c) {
+				t.Error("different seeds drew the same captcha")
+			}
+		})
+	}
+}
+
+// bigIntRandSource draws every number with crypto/rand.Int, like the
+// rendering did before RandSource, to compare with it.
+type bigIntRandSource struct{}
+
+// maxUint64 is 1<<64, the bound of bigIntRandSource draws.
+var maxUint64 = new(big.Int).Lsh(big.NewInt(1), 64)
+
+func (bigIntRandSource) Uint64() uint64 {
+	n, err := rand.Int(rand.Reader, maxUint64)
+	if err != nil {
+		panic(err)
+	}
+	return n.Uint64()
+}
+
+// bigIntN returns a number in [0, n) the way the rendering drew them before
+// RandSource.
+func bigIntN(n int) int {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		panic(err)
+	}
+	return int(v.Int64())
+}
+
+// benchmarkDrawCaptcha benchmarks DrawCaptcha of the driver returned by
+// newDriver, drawing with crypto/rand.Int like before RandSource, reading
+// crypto/rand for every draw and with the default ChaCha8 stream.
+func benchmarkDrawCaptcha(b *testing.B, newDriver func(src RandSource) Driver) {
+	for _, bb := range []struct {
+		name string
+		src  RandSource
+	}{
+		{"bigint", bigIntRandSource{}},
+		{"crypto", CryptoRandSource},
+		{"chacha8", nil},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			d := newDriver(bb.src)
+			_, q, _, err := d.GenerateIdQuestionAnswer()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := d.DrawCaptcha(q); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRandGen_intN(b *testing.B) {
+	b.Run("bigint", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			bigIntN(240)
+		}
+	})
+	for _, bb := range []struct {
+		name string
+		src  RandSource
+	}{
+		{"crypto", CryptoRandSource},
+		{"chacha8", nil},
+	} {
+		b.Run(bb.name, func(b *testing.B) {
+			r := newRandGen(bb.src)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				r.intN(240)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package base64Captcha
+
+import "context"
+
+// storeContextAdapter adapts a Store to the StoreContext interface.
+type storeContextAdapter struct {
+	store Store
+}
+
+// NewStoreContext returns a StoreContext backed by the given store. If the
+// store already implements StoreContext it is returned as is, otherwise the
+// returned adapter checks the context before every call to the store.
+func NewStoreContext(store Store) StoreContext {
+	if sc, ok := store.(StoreContext); ok {
+		return sc
+	}
+	return &storeContextAdapter{store: store}
+}
+
+// SetContext sets the digits for the captcha id.
+func (a *storeContextAdapter) SetContext(ctx context.Context, id string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return a.store.Set(id, value)
+}
+
+// SetRecordContext stores the answer of the record. A plain Store cannot
+// enforce attempt limits or bindings, so records using them are refused.
+func (a *storeContextAdapter) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	if rec.MaxAttempts != 0 || rec.Binding != "" {
+		return ErrRecordUnsupported
+	}
+	return a.SetContext(ctx, id, rec.Answer)
+}
+
+// GetContext returns stored digits for the captcha id.
+func (a *storeContextAdapter) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return a.store.Get(id, clear), nil
+}
+
+// VerifyContext verifies captcha's answer directly.
+func (a *storeContextAdapter) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return a.store.Verify(id, answer, clear), nil
+}
+
+// CheckContext verifies captcha's answer. A plain Store cannot tell why a
+// captcha is missing, so ErrNotFound covers expired and used captchas too.
+func (a *storeContextAdapter) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	v := a.store.Get(id, clear)
+	if v == "" {
+		return ErrNotFound
+	}
+	if !answerPolicyFrom(ctx, AnswerPolicy{}).Match(v, answer) {
+		return ErrMismatch
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package base64Captcha
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// legacyStore implements only the Store interface.
+type legacyStore struct {
+	m map[string]string
+}
+
+func (s *legacyStore) Set(id string, value string) error {
+	s.m[id] = value
+	return nil
+}
+
+func (s *legacyStore) Get(id string, clear bool) string {
+	v := s.m[id]
+	if clear {
+		delete(s.m, id)
+	}
+	return v
+}
+
+func (s *legacyStore) Verify(id, answer string, clear bool) bool {
+	return answer != "" && s.Get(id, clear) == answer
+}
+
+// downStore is a StoreContext whose backend is unavailable.
+type downStore struct{}
+
+var errStoreDown = errors.New("store down")
+
+func (downStore) SetContext(ctx context.Context, id string, value string) error {
+	return errStoreDown
+}
+
+func (downStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	return errStoreDown
+}
+
+func (downStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	return "", errStoreDown
+}
+
+func (downStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	return false, errStoreDown
+}
+
+func (downStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	return errStoreDown
+}
+
+func TestNewStoreContext(t *testing.T) {
+	mem := NewMemoryStore(10, Expiration)
+	if got := NewStoreContext(mem); got != mem.(StoreContext) {
+		t.Error("memory store should be returned as is")
+	}
+	if _, ok := NewStoreContext(&legacyStore{m: map[string]string{}}).(*storeContextAdapter); !ok {
+		t.Error("legacy store should be adapted")
+	}
+}
+
+func TestStoreContextAdapter(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreContext(&legacyStore{m: map[string]string{}})
+	if err := s.SetContext(ctx, "id", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := s.GetContext(ctx, "id", false); err != nil || v != "1234" {
+		t.Errorf("GetContext() = %q, %v", v, err)
+	}
+	if ok, err := s.VerifyContext(ctx, "id", "1234", true); err != nil || !ok {
+		t.Errorf("VerifyContext() = %v, %v", ok, err)
+	}
+	if ok, err := s.VerifyContext(ctx, "id", "1234", true); err != nil || ok {
+		t.Errorf("VerifyContext() after clear = %v, %v", ok, err)
+	}
+	_ = s.SetContext(ctx, "id", "1234")
+	if err := s.CheckContext(ctx, "id", "4321", false); err != ErrMismatch {
+		t.Errorf("CheckContext() = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != nil {
+		t.Errorf("CheckContext() = %v, want nil", err)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrNotFound {
+		t.Errorf("CheckContext() after clear = %v, want %v", err, ErrNotFound)
+	}
+
+	if err := s.SetRecordContext(ctx, "id", Record{Answer: "1234", MaxAttempts: 3}); err != ErrRecordUnsupported {
+		t.Errorf("SetRecordContext() error = %v, want %v", err, ErrRecordUnsupported)
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := s.SetContext(canceled, "id", "1234"); !errors.Is(err, context.Canceled) {
+		t.Errorf("SetContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := s.GetContext(canceled, "id", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetContext() error = %v, want %v", err, context.Canceled)
+	}
+	if _, err := s.VerifyContext(canceled, "id", "1234", false); !errors.Is(err, context.Canceled) {
+		t.Errorf("VerifyContext() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestStoreSyncMap_Context(t *testing.T) {
+	ctx := context.Background()
+	var s StoreContext = NewStoreSyncMap(liveTime)
+	if err := s.SetContext(ctx, "ctx", "answer"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := s.VerifyContext(ctx, "ctx", "answer", true); err != nil || !ok {
+		t.Errorf("VerifyContext() = %v, %v", ok, err)
+	}
+}
@@ -0,0 +1,135 @@
+package base64Captcha
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// hashStore keeps keyed hashes of the answers in the underlying store, so
+// that a dump of the store does not reveal the solution of live captchas.
+type hashStore struct {
+	store  Store
+	ctx    StoreContext
+	key    []byte
+	policy AnswerPolicy
+}
+
+// exactAnswerPolicy compares the hashes as they are.
+var exactAnswerPolicy = AnswerPolicy{Normalizer: AnswerNormalizerFunc(func(s string) string { return s })}
+
+// NewHashStore wraps a store so that it saves an HMAC-SHA256 of the
+// normalized answer, salted with the captcha id, instead of the plaintext
+// answer. Verification hashes the submitted answer the same way and lets the
+// underlying store compare the hashes in constant time. Only the Normalizer
+// of the answer policy applies, the hashes are compared for equality.
+//
+// Get returns the stored hash rather than the answer, use Verify to check
+// answers. The key must be kept secret and must be the same on every
+// instance sharing the underlying store.
+func NewHashStore(store Store, key []byte) Store {
+	return &hashStore{store: store, ctx: NewStoreContext(store), key: append([]byte(nil), key...)}
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *hashStore) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore if the underlying store does, it
+// returns zero otherwise.
+func (s *hashStore) Expiration() time.Duration {
+	if e, ok := s.store.(ExpiringStore); ok {
+		return e.Expiration()
+	}
+	return 0
+}
+
+// OnExpire implements ExpireNotifier if the underlying store does, fn is
+// never called otherwise.
+func (s *hashStore) OnExpire(fn func(e ExpireEvent)) {
+	if n, ok := s.store.(ExpireNotifier); ok {
+		n.OnExpire(fn)
+	}
+}
+
+// digest returns the keyed hash of the answer of captcha id. An empty answer
+// stays empty, so that it never matches.
+func (s *hashStore) digest(ctx context.Context, id, answer string) string {
+	return keyedDigest(s.key, id, answerPolicyFrom(ctx, s.policy).Normalize(answer))
+}
+
+// keyedDigest returns the HMAC-SHA256 under key of a value of captcha id,
+// hex encoded. An empty value stays empty.
+func keyedDigest(key []byte, id, value string) string {
+	if value == "" {
+		return ""
+	}
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(id))
+	h.Write([]byte{0})
+	h.Write([]byte(value))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Set sets the hashed answer for the captcha id.
+func (s *hashStore) Set(id string, value string) error {
+	return s.store.Set(id, s.digest(context.Background(), id, value))
+}
+
+// Get returns the stored hash for the captcha id.
+func (s *hashStore) Get(id string, clear bool) string {
+	return s.store.Get(id, clear)
+}
+
+// Verify hashes the answer and verifies it against the stored hash.
+func (s *hashStore) Verify(id, answer string, clear bool) bool {
+	return s.store.Verify(id, s.digest(context.Background(), id, answer), clear)
+}
+
+// SetContext implements StoreContext.
+func (s *hashStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.ctx.SetContext(withAnswerPolicy(ctx, exactAnswerPolicy), id, s.digest(ctx, id, value))
+}
+
+// SetRecordContext implements StoreContext.
+func (s *hashStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	rec.Answer = s.digest(ctx, id, rec.Answer)
+	return s.ctx.SetRecordContext(withAnswerPolicy(ctx, exactAnswerPolicy), id, rec)
+}
+
+// GetContext implements StoreContext.
+func (s *hashStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	return s.ctx.GetContext(withAnswerPolicy(ctx, exactAnswerPolicy), id, clear)
+}
+
+// VerifyContext implements StoreContext.
+func (s *hashStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	return s.ctx.VerifyContext(withAnswerPolicy(ctx, exactAnswerPolicy), id, s.digest(ctx, id, answer), clear)
+}
+
+// CheckContext implements StoreContext.
+func (s *hashStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	return s.ctx.CheckContext(withAnswerPolicy(ctx, exactAnswerPolicy), id, s.digest(ctx, id, answer), clear)
+}
+
+// ReplaceRecordContext implements ReplaceStore. If the wrapped store does
+// not implement it, the store cannot keep bindings either, and the record
+// is stored if the captcha is live.
+func (s *hashStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	rec.Answer = s.digest(ctx, id, rec.Answer)
+	ctx = withAnswerPolicy(ctx, exactAnswerPolicy)
+	if rs, ok := s.ctx.(ReplaceStore); ok {
+		return rs.ReplaceRecordContext(ctx, id, rec)
+	}
+	v, err := s.ctx.GetContext(ctx, id, false)
+	if err != nil {
+		return err
+	}
+	if v == "" {
+		return ErrNotFound
+	}
+	return s.ctx.SetRecordContext(ctx, id, rec)
+}
@@ -0,0 +1,63 @@
+package base64Captcha
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHashStore(t *testing.T) {
+	mem := NewMemoryStore(10, time.Hour)
+	s := NewHashStore(mem, []byte("secret"))
+	_ = s.Set("id", "AbC12")
+	if v := mem.Get("id", false); v == "" || strings.Contains(strings.ToLower(v), "abc12") {
+		t.Errorf("underlying store keeps %q", v)
+	}
+	if s.Verify("id", "", false) {
+		t.Error("empty answer verified")
+	}
+	if s.Verify("id", "abc13", false) {
+		t.Error("wrong answer verified")
+	}
+	if !s.Verify("id", " abc12 ", true) {
+		t.Error("right answer failed")
+	}
+	if s.Verify("id", "abc12", true) {
+		t.Error("cleared captcha verified")
+	}
+
+	_ = s.Set("other", "AbC12")
+	if mem.Get("other", false) == mem.Get("id", false) {
+		t.Error("hashes are not salted with the id")
+	}
+}
+
+func TestHashStore_Context(t *testing.T) {
+	ctx := context.Background()
+	s := NewHashStore(NewMemoryStore(10, time.Hour), []byte("secret")).(StoreContext)
+	_ = s.SetRecordContext(ctx, "id", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "id", "4321", true); err != ErrMismatch {
+		t.Errorf("CheckContext() = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != nil {
+		t.Errorf("CheckContext() = %v, want nil", err)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrAlreadyUsed {
+		t.Errorf("CheckContext() = %v, want %v", err, ErrAlreadyUsed)
+	}
+}
+
+func TestHashStore_Captcha(t *testing.T) {
+	c := NewCaptcha(DefaultDriverDigit, NewHashStore(NewMemoryStore(10, time.Hour), []byte("secret")))
+	id, _, answer, err := c.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Store.Get(id, false) == answer {
+		t.Error("plaintext answer stored")
+	}
+	if !c.Verify(id, answer, true) {
+		t.Error("Verify() failed")
+	}
+}
@@ -0,0 +1,470 @@
+package base64Captcha
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemcacheOptions configure a MemcacheStore.
+type MemcacheOptions struct {
+	// Servers are the host:port of the servers, "localhost:11211" by
+	// default. Every key is kept on one of them, chosen by hashing it.
+	Servers []string
+	// KeyPrefix is prepended to the captcha ids, for example "captcha:".
+	KeyPrefix string
+	// Expiration is the time to live of the captchas, Expiration by
+	// default.
+	Expiration time.Duration
+	// PoolSize is the number of idle connections kept for reuse for each
+	// server, 2 by default.
+	PoolSize int
+	// DialTimeout bounds connecting to a server, 5 seconds by default.
+	DialTimeout time.Duration
+}
+
+// errMemcacheClosed is returned by a MemcacheStore once it is closed.
+var errMemcacheClosed = errors.New("captcha: memcache store closed")
+
+// memcacheError is an error reply of the server.
+type memcacheError string
+
+func (e memcacheError) Error() string {
+	return "captcha: memcache: " + string(e)
+}
+
+// memcacheRelativeLimit is the longest expiration time memcached takes as
+// relative, longer ones are Unix timestamps.
+const memcacheRelativeLimit = 30 * 24 * time.Hour
+
+// MemcacheStore is a Store and StoreContext keeping the captchas in
+// memcached, over the text protocol.
+//
+// Captchas expire with the expiration time of the items. Verifications
+// update the items with gets and cas, so two concurrent verifications cannot
+// both consume a captcha. Used captchas are kept until they expire, so that
+// CheckContext reports a replay as ErrAlreadyUsed.
+type MemcacheStore struct {
+	opts    MemcacheOptions
+	policy  AnswerPolicy
+	servers []*memcacheServer
+}
+
+// NewMemcacheStore returns a store using the memcached servers of opts. It
+// connects lazily, use Ping to check the servers at startup.
+func NewMemcacheStore(opts MemcacheOptions) *MemcacheStore {
+	if len(opts.Servers) == 0 {
+		opts.Servers = []string{"localhost:11211"}
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = Expiration
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 2
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	s := &MemcacheStore{opts: opts}
+	for _, addr := range opts.Servers {
+		s.servers = append(s.servers, &memcacheServer{addr: addr, size: opts.PoolSize, dialTimeout: opts.DialTimeout})
+	}
+	return s
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *MemcacheStore) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore.
+func (s *MemcacheStore) Expiration() time.Duration {
+	return s.opts.Expiration
+}
+
+// Ping checks that every server answers.
+func (s *MemcacheStore) Ping(ctx context.Context) error {
+	for _, srv := range s.servers {
+		err := srv.do(ctx, func(c *memcacheConn) error {
+			c.w.WriteString("version\r\n")
+			if err := c.w.Flush(); err != nil {
+				return err
+			}
+			_, err := c.readLine()
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("%s: %w", srv.addr, err)
+		}
+	}
+	return nil
+}
+
+// Close closes the idle connections. Commands fail once the store is
+// closed.
+func (s *MemcacheStore) Close() error {
+	for _, srv := range s.servers {
+		srv.close()
+	}
+	return nil
+}
+
+// Set sets the digits for the captcha id.
+func (s *MemcacheStore) Set(id string, value string) error {
+	return s.SetContext(context.Background(), id, value)
+}
+
+// Get returns stored digits for the captcha id, or an empty string if the
+// server could not be reached.
+func (s *MemcacheStore) Get(id string, clear bool) string {
+	v, _ := s.GetContext(context.Background(), id, clear)
+	return v
+}
+
+// Verify captcha's answer directly.
+func (s *MemcacheStore) Verify(id, answer string, clear bool) bool {
+	return s.CheckContext(context.Background(), id, answer, clear) == nil
+}
+
+// SetContext implements StoreContext.
+func (s *MemcacheStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.SetRecordContext(ctx, id, Record{Answer: value})
+}
+
+// SetRecordContext implements StoreContext.
+func (s *MemcacheStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	ttl := s.opts.Expiration.Round(time.Second)
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	key, err := s.key(id)
+	if err != nil {
+		return err
+	}
+	return s.server(key).do(ctx, func(c *memcacheConn) error {
+		reply, err := c.store("set", key, newStoredRecord(rec, ttl).encode(), memcacheExptime(ttl), 0)
+		if err == nil && reply != "STORED" {
+			err = memcacheError(reply)
+		}
+		return err
+	})
+}
+
+// GetContext implements StoreContext.
+func (s *MemcacheStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	var answer string
+	err := s.update(ctx, id, func(rec *storedRecord) (bool, error) {
+		answer = ""
+		if rec.Used || rec.exhausted() || rec.ttl(time.Now()) <= 0 {
+			return false, nil
+		}
+		answer = rec.Answer
+		if clear {
+			rec.consume()
+		}
+		return clear, nil
+	})
+	if err == ErrNotFound {
+		err = nil
+	}
+	return answer, err
+}
+
+// VerifyContext implements StoreContext.
+func (s *MemcacheStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	err := s.CheckContext(ctx, id, answer, clear)
+	if err != nil && !isVerifyOutcome(err) {
+		return false, err
+	}
+	return err == nil, nil
+}
+
+// CheckContext implements StoreContext.
+func (s *MemcacheStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	return s.update(ctx, id, func(rec *storedRecord) (bool, error) {
+		reportRecordDriver(ctx, rec.Driver)
+		if err := rec.live(time.Now()); err != nil {
+			return false, err
+		}
+		if answer == "" {
+			return false, ErrMismatch
+		}
+		failure := rec.match(ctx, s.policy, answer)
+		if failure != nil && rec.MaxAttempts > 0 {
+			return true, rec.fail(failure)
+		}
+		if clear {
+			rec.consume()
+		}
+		return clear, failure
+	})
+}
+
+// ReplaceRecordContext implements ReplaceStore.
+func (s *MemcacheStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	binding := bindingFrom(ctx)
+	return s.update(ctx, id, func(stored *storedRecord) (bool, error) {
+		if err := stored.live(time.Now()); err != nil {
+			return false, err
+		}
+		if !bindingsEqual(stored.Binding, binding) {
+			return false, ErrBindingMismatch
+		}
+		*stored = *newStoredRecord(rec, s.opts.Expiration)
+		return true, nil
+	})
+}
+
+// update reads the record of captcha id with gets and passes it to fn. If
+// fn changed the record it is saved with cas, and fn runs again on the
+// latest record when another client changed it meanwhile. It returns the
+// error of fn, or ErrNotFound if there is no record.
+func (s *MemcacheStore) update(ctx context.Context, id string, fn func(rec *storedRecord) (save bool, err error)) error {
+	key, err := s.key(id)
+	if err != nil {
+		return err
+	}
+	srv := s.server(key)
+	for {
+		var (
+			value string
+			cas   uint64
+			found bool
+		)
+		err := srv.do(ctx, func(c *memcacheConn) (err error) {
+			value, cas, found, err = c.gets(key)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		rec, err := decodeStoredRecord(id, []byte(value))
+		if err != nil {
+			return err
+		}
+		save, result := fn(rec)
+		if !save {
+			return result
+		}
+		ttl := rec.ttl(time.Now())
+		if ttl < time.Second {
+			ttl = time.Second
+		}
+		var reply string
+		err = srv.do(ctx, func(c *memcacheConn) (err error) {
+			reply, err = c.store("cas", key, rec.encode(), memcacheExptime(ttl), cas)
+			return err
+		})
+		switch {
+		case err != nil:
+			return err
+		case reply == "STORED":
+			return result
+		case reply == "NOT_FOUND":
+			return ErrNotFound
+		case reply != "EXISTS":
+			return memcacheError(reply)
+		}
+	}
+}
+
+// key returns the key of captcha id. Memcached keys are at most 250 bytes
+// long, without white space or control characters.
+func (s *MemcacheStore) key(id string) (string, error) {
+	key := s.opts.KeyPrefix + id
+	if len(key) > 250 {
+		return "", fmt.Errorf("captcha: memcache: key too long: %q", key)
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return "", fmt.Errorf("captcha: memcache: invalid key %q", key)
+		}
+	}
+	return key, nil
+}
+
+// server returns the server keeping key, picked with the CRC-32 of the key
+// like most memcached clients do.
+func (s *MemcacheStore) server(key string) *memcacheServer {
+	return s.servers[crc32.ChecksumIEEE([]byte(key))%uint32(len(s.servers))]
+}
+
+// memcacheExptime returns the expiration time of an item living ttl.
+func memcacheExptime(ttl time.Duration) int64 {
+	secs := int64((ttl + time.Second - 1) / time.Second)
+	if ttl > memcacheRelativeLimit {
+		return time.Now().Unix() + secs
+	}
+	return secs
+}
+
+// memcacheServer keeps the idle connections to a server.
+type memcacheServer struct {
+	addr        string
+	size        int
+	dialTimeout time.Duration
+
+	mu     sync.Mutex
+	idle   []*memcacheConn
+	closed bool
+}
+
+// do runs fn on a connection to the server, within the deadline of ctx. An
+// error reply of the server is returned as a memcacheError. fn runs again on
+// a new connection if the server closed an idle connection meanwhile.
+func (srv *memcacheServer) do(ctx context.Context, fn func(c *memcacheConn) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	for {
+		c, pooled, err := srv.conn(ctx)
+		if err != nil {
+			return err
+		}
+		err = connDo(ctx, c, func() error { return fn(c) })
+		var merr memcacheError
+		if err == nil || errors.As(err, &merr) {
+			srv.put(c)
+			return err
+		}
+		if err = closeConn(ctx, c, err); pooled && isConnReset(err) {
+			continue
+		}
+		return err
+	}
+}
+
+// conn returns an idle connection, or a new one. It reports whether the
+// connection was idle.
+func (srv *memcacheServer) conn(ctx context.Context) (*memcacheConn, bool, error) {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		return nil, false, errMemcacheClosed
+	}
+	if n := len(srv.idle); n > 0 {
+		c := srv.idle[n-1]
+		srv.idle = srv.idle[:n-1]
+		srv.mu.Unlock()
+		return c, true, nil
+	}
+	srv.mu.Unlock()
+	d := net.Dialer{Timeout: srv.dialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", srv.addr)
+	if err != nil {
+		return nil, false, err
+	}
+	return &memcacheConn{Conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}, false, nil
+}
+
+// put keeps a connection for reuse, or closes it if the pool is full.
+func (srv *memcacheServer) put(c *memcacheConn) {
+	srv.mu.Lock()
+	if !srv.closed && len(srv.idle) < srv.size {
+		srv.idle = append(srv.idle, c)
+		c = nil
+	}
+	srv.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// close closes the idle connections.
+func (srv *memcacheServer) close() {
+	srv.mu.Lock()
+	idle := srv.idle
+	srv.idle, srv.closed = nil, true
+	srv.mu.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+}
+
+// memcacheConn is a connection speaking the memcached text protocol.
+type memcacheConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// store sends a storage command, cas only for the cas command, and returns
+// the reply, such as STORED or EXISTS.
+func (c *memcacheConn) store(verb, key, value string, exptime int64, cas uint64) (string, error) {
+	c.w.WriteString(verb + " " + key + " 0 " + strconv.FormatInt(exptime, 10) + " " + strconv.Itoa(len(value)))
+	if verb == "cas" {
+		c.w.WriteString(" " + strconv.FormatUint(cas, 10))
+	}
+	c.w.WriteString("\r\n" + value + "\r\n")
+	if err := c.w.Flush(); err != nil {
+		return "", err
+	}
+	return c.readLine()
+}
+
+// gets returns the value and the cas unique of key, found is false if there
+// is no such item.
+func (c *memcacheConn) gets(key string) (value string, cas uint64, found bool, err error) {
+	c.w.WriteString("gets " + key + "\r\n")
+	if err := c.w.Flush(); err != nil {
+		return "", 0, false, err
+	}
+	line, err := c.readLine()
+	if err != nil || line == "END" {
+		return "", 0, false, err
+	}
+	// VALUE <key> <flags> <bytes> <cas unique>
+	f := strings.Fields(line)
+	if len(f) != 5 || f[0] != "VALUE" || f[1] != key {
+		return "", 0, false, fmt.Errorf("captcha: memcache: malformed reply %q", line)
+	}
+	n, err := strconv.Atoi(f[3])
+	if err != nil || n < 0 {
+		return "", 0, false, fmt.Errorf("captcha: memcache: malformed reply %q", line)
+	}
+	if cas, err = strconv.ParseUint(f[4], 10, 64); err != nil {
+		return "", 0, false, fmt.Errorf("captcha: memcache: malformed reply %q", line)
+	}
+	b := make([]byte, n+2)
+	if _, err := io.ReadFull(c.r, b); err != nil {
+		return "", 0, false, err
+	}
+	if line, err = c.readLine(); err != nil {
+		return "", 0, false, err
+	}
+	if line != "END" {
+		return "", 0, false, fmt.Errorf("captcha: memcache: malformed reply %q", line)
+	}
+	return string(b[:n]), cas, true, nil
+}
+
+// readLine reads a reply line. Error replies are returned as a
+// memcacheError.
+func (c *memcacheConn) readLine() (string, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+	if line == "ERROR" || strings.HasPrefix(line, "CLIENT_ERROR ") || strings.HasPrefix(line, "SERVER_ERROR ") {
+		return "", memcacheError(line)
+	}
+	return line, nil
+}
@@ -0,0 +1,328 @@
+package base64Captcha
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeMemcache is an in-process server speaking enough of the memcached
+// text protocol for MemcacheStore.
+type fakeMemcache struct {
+	ln net.Listener
+
+	mu       sync.Mutex
+	items    map[string]fakeMemcacheItem
+	nextCas  uint64
+	commands []string
+	conns    []net.Conn
+}
+
+type fakeMemcacheItem struct {
+	value   string
+	cas     uint64
+	expires time.Time
+}
+
+func newFakeMemcache(t *testing.T) *fakeMemcache {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeMemcache{ln: ln, items: make(map[string]fakeMemcacheItem)}
+	go f.serve()
+	t.Cleanup(func() {
+		ln.Close()
+		f.dropConns()
+	})
+	return f
+}
+
+func (f *fakeMemcache) addr() string {
+	return f.ln.Addr().String()
+}
+
+// dropConns closes the connections, as a server timing out idle clients.
+func (f *fakeMemcache) dropConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+// sent returns the command lines received.
+func (f *fakeMemcache) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+// keys returns the number of items stored.
+func (f *fakeMemcache) keys() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.items)
+}
+
+func (f *fakeMemcache) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.conns = append(f.conns, c)
+		f.mu.Unlock()
+		go f.handle(c)
+	}
+}
+
+func (f *fakeMemcache) handle(c net.Conn) {
+	defer c.Close()
+	r, w := bufio.NewReader(c), bufio.NewWriter(c)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		args := strings.Fields(line)
+		var data string
+		if len(args) >= 5 && args[0] != "gets" {
+			n, _ := strconv.Atoi(args[4])
+			b := make([]byte, n+2)
+			if _, err := io.ReadFull(r, b); err != nil {
+				return
+			}
+			data = string(b[:n])
+		}
+		w.WriteString(f.exec(args, data))
+		if w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// exec runs a command and returns the encoded reply.
+func (f *fakeMemcache) exec(args []string, data string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, strings.Join(args, " "))
+	if len(args) == 0 {
+		return "ERROR\r\n"
+	}
+	item, found := fakeMemcacheItem{}, false
+	if len(args) > 1 {
+		item, found = f.items[args[1]]
+		if found && time.Now().After(item.expires) {
+			delete(f.items, args[1])
+			found = false
+		}
+	}
+	switch args[0] {
+	case "version":
+		return "VERSION 1.6.0\r\n"
+	case "gets":
+		if !found {
+			return "END\r\n"
+		}
+		return "VALUE " + args[1] + " 0 " + strconv.Itoa(len(item.value)) + " " + strconv.FormatUint(item.cas, 10) + "\r\n" + item.value + "\r\nEND\r\n"
+	case "set", "cas":
+		if args[0] == "cas" {
+			switch {
+			case !found:
+				return "NOT_FOUND\r\n"
+			case args[5] != strconv.FormatUint(item.cas, 10):
+				return "EXISTS\r\n"
+			}
+		}
+		exptime, _ := strconv.ParseInt(args[3], 10, 64)
+		expires := time.Now().Add(time.Duration(exptime) * time.Second)
+		if exptime > int64(memcacheRelativeLimit/time.Second) {
+			expires = time.Unix(exptime, 0)
+		}
+		f.nextCas++
+		f.items[args[1]] = fakeMemcacheItem{data, f.nextCas, expires}
+		return "STORED\r\n"
+	}
+	return "ERROR\r\n"
+}
+
+func TestMemcacheStore(t *testing.T) {
+	f := newFakeMemcache(t)
+	s := NewMemcacheStore(MemcacheOptions{Servers: []string{f.addr()}, KeyPrefix: "captcha:", Expiration: 90 * time.Second})
+	defer s.Close()
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("id", "AbC12"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get("id", false); v != "AbC12" {
+		t.Errorf("Get() = %q", v)
+	}
+	if s.Verify("id", "abc13", false) {
+		t.Error("wrong answer verified")
+	}
+	if !s.Verify("id", " abc12 ", true) {
+		t.Error("right answer failed")
+	}
+	if s.Verify("id", "abc12", true) {
+		t.Error("cleared captcha verified")
+	}
+	if err := s.CheckContext(context.Background(), "id", "abc12", true); err != ErrAlreadyUsed {
+		t.Errorf("CheckContext() of a used captcha = %v", err)
+	}
+
+	s.Set("other", "1234")
+	if v := s.Get("other", true); v != "1234" {
+		t.Errorf("Get() = %q", v)
+	}
+	if v := s.Get("other", false); v != "" {
+		t.Errorf("Get() after clear = %q", v)
+	}
+
+	if sent := f.sent(); sent[1] != "set captcha:id 0 90 "+strings.Fields(sent[1])[4] {
+		t.Errorf("Set sent %q", sent[1])
+	}
+	if err := s.Set("bad id", "1234"); err == nil {
+		t.Error("Set() of an id with a space succeeded")
+	}
+}
+
+func TestMemcacheStore_Check(t *testing.T) {
+	f := newFakeMemcache(t)
+	s := NewMemcacheStore(MemcacheOptions{Servers: []string{f.addr()}})
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.CheckContext(ctx, "missing", "1234", true); err != ErrNotFound {
+		t.Errorf("CheckContext() of a missing captcha = %v", err)
+	}
+	s.SetRecordContext(ctx, "limited", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "limited", "", true); err != ErrMismatch {
+		t.Errorf("CheckContext() of an empty answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", true); err != ErrMismatch {
+		t.Errorf("first wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", false); err != ErrTooManyAttempts {
+		t.Errorf("second wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "1234", true); err != ErrTooManyAttempts {
+		t.Errorf("right answer after too many attempts = %v", err)
+	}
+
+	s.SetRecordContext(ctx, "bound", Record{Answer: "1234", Binding: "sess"})
+	if err := s.CheckContext(ctx, "bound", "1234", false); err != ErrBindingMismatch {
+		t.Errorf("CheckContext() without binding = %v", err)
+	}
+	sess := ContextWithBinding(ctx, "sess")
+	if err := s.ReplaceRecordContext(ctx, "bound", Record{Answer: "5678"}); err != ErrBindingMismatch {
+		t.Errorf("ReplaceRecordContext() without binding = %v", err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "5678", Binding: "sess"}); err != nil {
+		t.Errorf("ReplaceRecordContext() with binding = %v", err)
+	}
+	if ok, err := s.VerifyContext(sess, "bound", "5678", true); !ok || err != nil {
+		t.Errorf("VerifyContext() of the new answer = %v, %v", ok, err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "9999", Binding: "sess"}); err != ErrAlreadyUsed {
+		t.Errorf("ReplaceRecordContext() of a used captcha = %v", err)
+	}
+	if ok, _ := s.VerifyContext(sess, "bound", "9999", true); ok {
+		t.Error("ReplaceRecordContext() revived a used captcha")
+	}
+}
+
+func TestMemcacheStore_concurrentVerify(t *testing.T) {
+	f := newFakeMemcache(t)
+	s := NewMemcacheStore(MemcacheOptions{Servers: []string{f.addr()}, PoolSize: 8})
+	defer s.Close()
+	s.Set("id", "1234")
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		matches int
+	)
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Verify("id", "1234", true) {
+				mu.Lock()
+				matches++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if matches != 1 {
+		t.Errorf("%d concurrent verifications matched, want 1", matches)
+	}
+}
+
+func TestMemcacheStore_servers(t *testing.T) {
+	f1, f2 := newFakeMemcache(t), newFakeMemcache(t)
+	s := NewMemcacheStore(MemcacheOptions{Servers: []string{f1.addr(), f2.addr()}})
+	defer s.Close()
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, 50)
+	for i := range ids {
+		ids[i] = RandomId()
+		if err := s.Set(ids[i], "1234"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if f1.keys() == 0 || f2.keys() == 0 || f1.keys()+f2.keys() != len(ids) {
+		t.Errorf("servers keep %d and %d captchas", f1.keys(), f2.keys())
+	}
+	for _, id := range ids {
+		if !s.Verify(id, "1234", true) {
+			t.Fatalf("captcha %s not found", id)
+		}
+	}
+}
+
+func TestMemcacheStore_errors(t *testing.T) {
+	f := newFakeMemcache(t)
+	s := NewMemcacheStore(MemcacheOptions{Servers: []string{f.addr()}})
+	s.Set("id", "1234")
+	f.dropConns()
+	if v := s.Get("id", false); v != "1234" {
+		t.Errorf("Get() after the server closed the connection = %q", v)
+	}
+	s.Close()
+	if err := s.Set("id", "1234"); !errors.Is(err, errMemcacheClosed) {
+		t.Errorf("Set() after Close = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	s = NewMemcacheStore(MemcacheOptions{Servers: []string{f.addr()}})
+	defer s.Close()
+	if _, err := s.VerifyContext(ctx, "id", "1234", true); err != context.Canceled {
+		t.Errorf("VerifyContext() canceled = %v", err)
+	}
+}
+
+func Test_memcacheExptime(t *testing.T) {
+	if got := memcacheExptime(90*time.Second + time.Millisecond); got != 91 {
+		t.Errorf("memcacheExptime() = %d, want 91", got)
+	}
+	if got := memcacheExptime(40 * 24 * time.Hour); got < time.Now().Unix() {
+		t.Errorf("memcacheExptime() of 40 days = %d, want a Unix time", got)
+	}
+}
@@ -16,7 +16,7 @@ package base64Captcha
 
 import (
 	"container/list"
-	"strings"
+	"context"
 	"sync"
 	"time"
 )
@@ -29,17 +29,42 @@ type idByTimeValue struct {
 	id        string
 }
 
+// memoryRecord is the value stored for a captcha id.
+type memoryRecord struct {
+	value   string
+	created time.Time
+	// used is set once the captcha has been consumed. The record is kept
+	// until it expires, so that a replay can be told apart from a typo.
+	used bool
+	// maxAttempts and failures implement the attempt limit of Record.
+	maxAttempts int
+	failures    int
+	binding     string
+	driver      string
+}
+
+// exhausted reports whether the record used up its attempts.
+func (rec *memoryRecord) exhausted() bool {
+	return rec.maxAttempts > 0 && rec.failures >= rec.maxAttempts
+}
+
 // memoryStore is an internal store for captcha ids and their values.
 type memoryStore struct {
 	sync.RWMutex
-	digitsById map[string]string
+	digitsById map[string]*memoryRecord
 	idByTime   *list.List
 	// Number of items stored since last collection.
 	numStored int
 	// Number of saved items that triggers collection.
 	collectNum int
+	// collecting is set while a collection is running, so that stores
+	// made during a collection do not start another one.
+	collecting bool
 	// Expiration time of captchas.
 	expiration time.Duration
+	// policy compares the answers.
+	policy AnswerPolicy
+	expireHook
 }
 
 // NewMemoryStore returns a new standard memory store for captchas with the
@@ -47,7 +72,7 @@ type memoryStore struct {
 // store must be registered with SetCustomStore to replace the default one.
 func NewMemoryStore(collectNum int, expiration time.Duration) Store {
 	s := new(memoryStore)
-	s.digitsById = make(map[string]string)
+	s.digitsById = make(map[string]*memoryRecord)
 	s.idByTime = list.New()
 	s.collectNum = collectNum
 	s.expiration = expiration
@@ -55,11 +80,29 @@ func NewMemoryStore(collectNum int, expiration time.Duration) Store {
 }
 
 func (s *memoryStore) Set(id string, value string) error {
+	return s.setRecord(id, Record{Answer: value}, nil)
+}
+
+// setRecord stores the record of captcha id. If cond is not nil, it is
+// called with the current record under the lock, and the record is only
+// stored if it returns nil.
+func (s *memoryStore) setRecord(id string, r Record, cond func(rec *memoryRecord, ok bool) error) error {
+	now := time.Now()
 	s.Lock()
-	s.digitsById[id] = value
-	s.idByTime.PushBack(idByTimeValue{time.Now(), id})
+	if cond != nil {
+		rec, ok := s.digitsById[id]
+		if err := cond(rec, ok); err != nil {
+			s.Unlock()
+			return err
+		}
+	}
+	s.digitsById[id] = &memoryRecord{value: r.Answer, created: now, maxAttempts: r.MaxAttempts, binding: r.Binding, driver: r.Driver}
+	s.idByTime.PushBack(idByTimeValue{now, id})
 	s.numStored++
-	needCollect := s.numStored > s.collectNum
+	needCollect := s.numStored > s.collectNum && !s.collecting
+	if needCollect {
+		s.collecting = true
+	}
 	s.Unlock()
 	if needCollect {
 		go s.collect()
@@ -68,11 +111,17 @@ func (s *memoryStore) Set(id string, value string) error {
 }
 
 func (s *memoryStore) Verify(id, answer string, clear bool) bool {
-	if id == "" || answer == "" {
-		return false
-	}
-	v := s.Get(id, clear)
-	return strings.EqualFold(v, answer)
+	return s.check(context.Background(), id, answer, clear) == nil
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *memoryStore) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore.
+func (s *memoryStore) Expiration() time.Duration {
+	return s.expiration
 }
 
 func (s *memoryStore) Get(id string, clear bool) (value string) {
@@ -84,38 +133,172 @@ func (s *memoryStore) Get(id string, clear bool) (value string) {
 		s.Lock()
 		defer s.Unlock()
 	}
-	value, ok := s.digitsById[id]
-	if !ok {
+	rec, ok := s.digitsById[id]
+	if !ok || rec.used || rec.exhausted() || s.expired(rec, time.Now()) {
 		return
 	}
 	if clear {
-		delete(s.digitsById, id)
+		rec.used = true
+	}
+	return rec.value
+}
+
+// check verifies the answer under the answer policy and binding of ctx, and
+// reports why it failed.
+func (s *memoryStore) check(ctx context.Context, id, answer string, clear bool) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	s.Lock()
+	defer s.Unlock()
+	rec, ok := s.digitsById[id]
+	if ok {
+		reportRecordDriver(ctx, rec.driver)
+	}
+	if err := s.live(rec, ok); err != nil {
+		return err
+	}
+	if answer == "" {
+		return ErrMismatch
+	}
+	var failure error
+	switch {
+	case !bindingsEqual(rec.binding, bindingFrom(ctx)):
+		failure = ErrBindingMismatch
+	case !answerPolicyFrom(ctx, s.policy).Match(rec.value, answer):
+		failure = ErrMismatch
+	}
+	if failure != nil && rec.maxAttempts > 0 {
+		rec.failures++
+		if rec.exhausted() {
+			return ErrTooManyAttempts
+		}
+		return failure
+	}
+	if clear {
+		rec.used = true
+	}
+	return failure
+}
+
+// live returns why the record found for an id cannot be verified any more,
+// or nil if it can.
+func (s *memoryStore) live(rec *memoryRecord, ok bool) error {
+	switch {
+	case !ok:
+		return ErrNotFound
+	case rec.used:
+		return ErrAlreadyUsed
+	case rec.exhausted():
+		return ErrTooManyAttempts
+	case s.expired(rec, time.Now()):
+		return ErrExpired
+	}
+	return nil
+}
+
+// expired reports whether the record outlived the store expiration.
+func (s *memoryStore) expired(rec *memoryRecord, now time.Time) bool {
+	return rec.created.Add(s.expiration).Before(now)
+}
+
+// SetContext implements StoreContext.
+func (s *memoryStore) SetContext(ctx context.Context, id string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
-	return
+	return s.Set(id, value)
+}
+
+// SetRecordContext implements StoreContext.
+func (s *memoryStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.setRecord(id, rec, nil)
+}
+
+// GetContext implements StoreContext.
+func (s *memoryStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.Get(id, clear), nil
+}
+
+// VerifyContext implements StoreContext.
+func (s *memoryStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.check(ctx, id, answer, clear) == nil, nil
+}
+
+// CheckContext implements StoreContext.
+func (s *memoryStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.check(ctx, id, answer, clear)
+}
+
+// ReplaceRecordContext implements ReplaceStore.
+func (s *memoryStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding := bindingFrom(ctx)
+	return s.setRecord(id, rec, func(cur *memoryRecord, ok bool) error {
+		if err := s.live(cur, ok); err != nil {
+			return err
+		}
+		if !bindingsEqual(cur.binding, binding) {
+			return ErrBindingMismatch
+		}
+		return nil
+	})
 }
 
 func (s *memoryStore) collect() {
 	now := time.Now()
+	fn := s.expireFunc()
+	var expired []string
 	s.Lock()
-	defer s.Unlock()
 	for e := s.idByTime.Front(); e != nil; {
-		e = s.collectOne(e, now)
+		var rec *memoryRecord
+		e, rec = s.collectOne(e, now)
+		if fn != nil && rec != nil && !rec.used {
+			expired = append(expired, rec.driver)
+		}
+	}
+	s.collecting = false
+	s.Unlock()
+	for _, driver := range expired {
+		fn(ExpireEvent{Driver: driver})
 	}
 }
 
-func (s *memoryStore) collectOne(e *list.Element, specifyTime time.Time) *list.Element {
+// collectOne deletes the captcha of e if it expired at specifyTime. It
+// returns the next element to collect, and the deleted record if any.
+func (s *memoryStore) collectOne(e *list.Element, specifyTime time.Time) (*list.Element, *memoryRecord) {
 
 	ev, ok := e.Value.(idByTimeValue)
 	if !ok {
-		return nil
+		return nil, nil
 	}
 
 	if ev.timestamp.Add(s.expiration).Before(specifyTime) {
-		delete(s.digitsById, ev.id)
+		// The id may have been stored again since, keep the newer record.
+		rec, ok := s.digitsById[ev.id]
+		if ok && !rec.created.After(ev.timestamp) {
+			delete(s.digitsById, ev.id)
+		} else {
+			rec = nil
+		}
 		next := e.Next()
 		s.idByTime.Remove(e)
 		s.numStored--
-		return next
+		return next, rec
 	}
-	return nil
+	return nil, nil
 }
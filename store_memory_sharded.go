@@ -0,0 +1,102 @@
+package base64Captcha
+
+import (
+	"context"
+	"hash/maphash"
+	"runtime"
+	"time"
+)
+
+// shardedMemoryStore spreads the captchas over memory stores, each with its
+// own lock and expiry list, so that verifications of different captchas
+// rarely wait for each other or for a collection.
+type shardedMemoryStore struct {
+	seed   maphash.Seed
+	shards []*memoryStore
+}
+
+// NewShardedMemoryStore returns a memory store split into the given number
+// of shards, four per CPU if shards is not positive. Each shard collects
+// its expired captchas after collectNum/shards captchas have been stored in
+// it, holding only its own lock. Use it instead of NewMemoryStore when many
+// goroutines verify captchas at the same time.
+func NewShardedMemoryStore(shards, collectNum int, expiration time.Duration) Store {
+	if shards <= 0 {
+		shards = 4 * runtime.GOMAXPROCS(0)
+	}
+	collectNum /= shards
+	if collectNum < 1 {
+		collectNum = 1
+	}
+	s := &shardedMemoryStore{seed: maphash.MakeSeed(), shards: make([]*memoryStore, shards)}
+	for i := range s.shards {
+		s.shards[i] = NewMemoryStore(collectNum, expiration).(*memoryStore)
+	}
+	return s
+}
+
+// shard returns the shard keeping captcha id.
+func (s *shardedMemoryStore) shard(id string) *memoryStore {
+	return s.shards[maphash.String(s.seed, id)%uint64(len(s.shards))]
+}
+
+func (s *shardedMemoryStore) Set(id string, value string) error {
+	return s.shard(id).Set(id, value)
+}
+
+func (s *shardedMemoryStore) Get(id string, clear bool) string {
+	return s.shard(id).Get(id, clear)
+}
+
+func (s *shardedMemoryStore) Verify(id, answer string, clear bool) bool {
+	return s.shard(id).Verify(id, answer, clear)
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *shardedMemoryStore) SetAnswerPolicy(p AnswerPolicy) {
+	for _, shard := range s.shards {
+		shard.SetAnswerPolicy(p)
+	}
+}
+
+// Expiration implements ExpiringStore.
+func (s *shardedMemoryStore) Expiration() time.Duration {
+	return s.shards[0].Expiration()
+}
+
+// OnExpire implements ExpireNotifier.
+func (s *shardedMemoryStore) OnExpire(fn func(e ExpireEvent)) {
+	for _, shard := range s.shards {
+		shard.OnExpire(fn)
+	}
+}
+
+// SetContext implements StoreContext.
+func (s *shardedMemoryStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.shard(id).SetContext(ctx, id, value)
+}
+
+// SetRecordContext implements StoreContext.
+func (s *shardedMemoryStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	return s.shard(id).SetRecordContext(ctx, id, rec)
+}
+
+// GetContext implements StoreContext.
+func (s *shardedMemoryStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	return s.shard(id).GetContext(ctx, id, clear)
+}
+
+// VerifyContext implements StoreContext.
+func (s *shardedMemoryStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	return s.shard(id).VerifyContext(ctx, id, answer, clear)
+}
+
+// CheckContext implements StoreContext.
+func (s *shardedMemoryStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	return s.shard(id).CheckContext(ctx, id, answer, clear)
+}
+
+// ReplaceRecordContext implements ReplaceStore.
+func (s *shardedMemoryStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	return s.shard(id).ReplaceRecordContext(ctx, id, rec)
+}
@@ -0,0 +1,95 @@
+package base64Captcha
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestShardedMemoryStore_OnExpire(t *testing.T) {
+	s := NewShardedMemoryStore(4, 4, time.Millisecond).(*shardedMemoryStore)
+	var expired atomic.Int64
+	s.OnExpire(func(ExpireEvent) { expired.Add(1) })
+	for i := 0; i < 8; i++ {
+		s.Set(strconv.Itoa(i), "1234")
+	}
+	time.Sleep(5 * time.Millisecond)
+	for _, shard := range s.shards {
+		shard.collect()
+	}
+	if n := expired.Load(); n != 8 {
+		t.Errorf("%d captchas reported expired, want 8", n)
+	}
+}
+
+func TestShardedMemoryStore(t *testing.T) {
+	s := NewShardedMemoryStore(8, 80, time.Hour)
+	sharded := s.(*shardedMemoryStore)
+	if len(sharded.shards) != 8 || sharded.shards[0].collectNum != 10 {
+		t.Fatalf("%d shards collecting after %d captchas", len(sharded.shards), sharded.shards[0].collectNum)
+	}
+	used := make(map[*memoryStore]bool)
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		if err := s.Set(id, id); err != nil {
+			t.Fatal(err)
+		}
+		used[sharded.shard(id)] = true
+	}
+	if len(used) < 2 {
+		t.Errorf("captchas kept in %d shards", len(used))
+	}
+	for i := 0; i < 100; i++ {
+		id := strconv.Itoa(i)
+		if v := s.Get(id, false); v != id {
+			t.Errorf("Get(%q) = %q", id, v)
+		}
+		if !s.Verify(id, id, true) || s.Verify(id, id, true) {
+			t.Errorf("captcha %s not consumed once", id)
+		}
+	}
+
+	ctx := context.Background()
+	sc := s.(StoreContext)
+	sc.SetRecordContext(ctx, "limited", Record{Answer: "1234", MaxAttempts: 1})
+	if err := sc.CheckContext(ctx, "limited", "4321", true); err != ErrTooManyAttempts {
+		t.Errorf("CheckContext() of a wrong answer = %v", err)
+	}
+	s.(AnswerPolicyStore).SetAnswerPolicy(AnswerPolicy{Normalizer: ConfusableNormalizer})
+	sc.SetContext(ctx, "confusable", "1010")
+	if ok, err := sc.VerifyContext(ctx, "confusable", "lOIO", true); !ok || err != nil {
+		t.Errorf("VerifyContext() under the answer policy = %v, %v", ok, err)
+	}
+	if e := s.(ExpiringStore).Expiration(); e != time.Hour {
+		t.Errorf("Expiration() = %v", e)
+	}
+	if NewShardedMemoryStore(0, 0, time.Hour).(*shardedMemoryStore).shards[0].collectNum != 1 {
+		t.Error("shards collect after less than one captcha")
+	}
+}
+
+// benchmarkStoreParallel sets and verifies captchas from parallel
+// goroutines.
+func benchmarkStoreParallel(b *testing.B, s Store) {
+	var n atomic.Uint64
+	b.ReportAllocs()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			id := strconv.FormatUint(n.Add(1), 36)
+			s.Set(id, "1234")
+			if !s.Verify(id, "1234", true) {
+				b.Errorf("captcha %s not verified", id)
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryStore_parallel(b *testing.B) {
+	benchmarkStoreParallel(b, NewMemoryStore(GCLimitNumber, time.Second))
+}
+
+func BenchmarkShardedMemoryStore_parallel(b *testing.B) {
+	benchmarkStoreParallel(b, NewShardedMemoryStore(0, GCLimitNumber, time.Second))
+}
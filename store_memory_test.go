@@ -15,6 +15,7 @@
 package base64Captcha
 
 import (
+	"context"
 	"fmt"
 	"math/rand"
 	"testing"
@@ -166,3 +167,70 @@ func Test_memoryStore_Get(t *testing.T) {
 	}
 
 }
+
+func Test_memoryStore_CheckContext(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(10, time.Hour).(*memoryStore)
+	_ = s.Set("id", "1234")
+	if err := s.CheckContext(ctx, "id", "", true); err != ErrMismatch {
+		t.Errorf("empty answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "4321", false); err != ErrMismatch {
+		t.Errorf("wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != nil {
+		t.Errorf("right answer = %v, want nil", err)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrAlreadyUsed {
+		t.Errorf("replay = %v, want %v", err, ErrAlreadyUsed)
+	}
+	if err := s.CheckContext(ctx, "missing", "1234", true); err != ErrNotFound {
+		t.Errorf("missing = %v, want %v", err, ErrNotFound)
+	}
+
+	_ = s.Set("old", "1234")
+	s.digitsById["old"].created = time.Now().Add(-2 * time.Hour)
+	if err := s.CheckContext(ctx, "old", "1234", true); err != ErrExpired {
+		t.Errorf("expired = %v, want %v", err, ErrExpired)
+	}
+	if v := s.Get("old", false); v != "" {
+		t.Errorf("Get() of expired captcha = %q", v)
+	}
+}
+
+func TestMemoryStore_CollectKeepsNewerRecord(t *testing.T) {
+	s := NewMemoryStore(1, time.Hour).(*memoryStore)
+	_ = s.Set("id", "old")
+	s.idByTime.Front().Value = idByTimeValue{time.Now().Add(-2 * time.Hour), "id"}
+	_ = s.Set("id", "new")
+	s.collect()
+	if v := s.Get("id", false); v != "new" {
+		t.Errorf("Get() = %q, want %q", v, "new")
+	}
+}
+
+func Test_memoryStore_MaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore(10, time.Hour).(*memoryStore)
+	_ = s.SetRecordContext(ctx, "id", Record{Answer: "1234", MaxAttempts: 3})
+	if err := s.CheckContext(ctx, "id", "1111", true); err != ErrMismatch {
+		t.Errorf("1st wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != nil {
+		t.Errorf("fixed typo = %v, want nil", err)
+	}
+
+	_ = s.SetRecordContext(ctx, "id", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "id", "1111", true); err != ErrMismatch {
+		t.Errorf("1st wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "2222", true); err != ErrTooManyAttempts {
+		t.Errorf("2nd wrong answer = %v, want %v", err, ErrTooManyAttempts)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrTooManyAttempts {
+		t.Errorf("right answer after limit = %v, want %v", err, ErrTooManyAttempts)
+	}
+	if v := s.Get("id", false); v != "" {
+		t.Errorf("Get() of exhausted captcha = %q", v)
+	}
+}
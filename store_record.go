@@ -0,0 +1,101 @@
+package base64Captcha
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// storedRecord is a Record as saved by the stores backed by a network
+// service, which keep it as a JSON string.
+type storedRecord struct {
+	Answer      string `json:"a"`
+	MaxAttempts int    `json:"m,omitempty"`
+	Failures    int    `json:"f,omitempty"`
+	Binding     string `json:"b,omitempty"`
+	Driver      string `json:"d,omitempty"`
+	// Used marks a consumed captcha, for stores which keep it until it
+	// expires.
+	Used bool `json:"u,omitempty"`
+	// Deadline is when the captcha expires, in Unix milliseconds, so that
+	// a record can be saved again with the rest of its time to live.
+	Deadline int64 `json:"e"`
+}
+
+// newStoredRecord returns the stored form of rec, expiring after ttl.
+func newStoredRecord(rec Record, ttl time.Duration) *storedRecord {
+	return &storedRecord{
+		Answer:      rec.Answer,
+		MaxAttempts: rec.MaxAttempts,
+		Binding:     rec.Binding,
+		Driver:      rec.Driver,
+		Deadline:    time.Now().Add(ttl).UnixMilli(),
+	}
+}
+
+// decodeStoredRecord decodes the record saved for captcha id.
+func decodeStoredRecord(id string, b []byte) (*storedRecord, error) {
+	rec := new(storedRecord)
+	if err := json.Unmarshal(b, rec); err != nil {
+		return nil, fmt.Errorf("captcha: decoding record %q: %w", id, err)
+	}
+	return rec, nil
+}
+
+// encode returns the JSON form of the record.
+func (rec *storedRecord) encode() string {
+	b, _ := json.Marshal(rec)
+	return string(b)
+}
+
+// exhausted reports whether the record used up its attempts.
+func (rec *storedRecord) exhausted() bool {
+	return rec.MaxAttempts > 0 && rec.Failures >= rec.MaxAttempts
+}
+
+// live returns why the record cannot be verified any more, or nil if it
+// can.
+func (rec *storedRecord) live(now time.Time) error {
+	switch {
+	case rec.Used:
+		return ErrAlreadyUsed
+	case rec.exhausted():
+		return ErrTooManyAttempts
+	case rec.ttl(now) <= 0:
+		return ErrExpired
+	}
+	return nil
+}
+
+// consume marks the record used and forgets its answer.
+func (rec *storedRecord) consume() {
+	rec.Used, rec.Answer = true, ""
+}
+
+// ttl returns the time left before the record expires.
+func (rec *storedRecord) ttl(now time.Time) time.Duration {
+	return time.UnixMilli(rec.Deadline).Sub(now)
+}
+
+// match compares the answer with the record under the answer policy and
+// binding of ctx, and returns ErrBindingMismatch or ErrMismatch on failure.
+func (rec *storedRecord) match(ctx context.Context, policy AnswerPolicy, answer string) error {
+	switch {
+	case !bindingsEqual(rec.Binding, bindingFrom(ctx)):
+		return ErrBindingMismatch
+	case !answerPolicyFrom(ctx, policy).Match(rec.Answer, answer):
+		return ErrMismatch
+	}
+	return nil
+}
+
+// fail counts a failed verification and returns the error to report, which
+// is ErrTooManyAttempts once the attempts are used up.
+func (rec *storedRecord) fail(failure error) error {
+	rec.Failures++
+	if rec.exhausted() {
+		return ErrTooManyAttempts
+	}
+	return failure
+}
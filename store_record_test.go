@@ -0,0 +1,38 @@
+package base64Captcha
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestStoredRecord(t *testing.T) {
+	rec := newStoredRecord(Record{Answer: "AbC", MaxAttempts: 2, Binding: "sess"}, time.Minute)
+	got, err := decodeStoredRecord("id", []byte(rec.encode()))
+	if err != nil || *got != *rec {
+		t.Fatalf("decodeStoredRecord() = %+v, %v, want %+v", got, err, rec)
+	}
+	if ttl := got.ttl(time.Now()); ttl <= 0 || ttl > time.Minute {
+		t.Errorf("ttl() = %v", ttl)
+	}
+	if _, err := decodeStoredRecord("id", []byte("1234")); err == nil {
+		t.Error("decodeStoredRecord() of a plain answer succeeded")
+	}
+
+	ctx := ContextWithBinding(context.Background(), "sess")
+	if err := got.match(ctx, AnswerPolicy{}, " abc "); err != nil {
+		t.Errorf("match() = %v", err)
+	}
+	if err := got.match(context.Background(), AnswerPolicy{}, "abc"); err != ErrBindingMismatch {
+		t.Errorf("match() without binding = %v, want ErrBindingMismatch", err)
+	}
+	if err := got.match(ctx, AnswerPolicy{}, "abd"); err != ErrMismatch {
+		t.Errorf("match() of a wrong answer = %v, want ErrMismatch", err)
+	}
+	if err := got.fail(ErrMismatch); err != ErrMismatch || got.exhausted() {
+		t.Errorf("first fail() = %v, exhausted %v", err, got.exhausted())
+	}
+	if err := got.fail(ErrMismatch); err != ErrTooManyAttempts || !got.exhausted() {
+		t.Errorf("second fail() = %v, exhausted %v", err, got.exhausted())
+	}
+}
@@ -0,0 +1,460 @@
+package base64Captcha
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RedisOptions configure a RedisStore.
+type RedisOptions struct {
+	// Addr is the host:port of the server, "localhost:6379" by default.
+	Addr string
+	// Username and Password authenticate the connections with AUTH when
+	// Password is set. Username is only needed for ACL users.
+	Username string
+	Password string
+	// DB is the database selected on every connection.
+	DB int
+	// KeyPrefix is prepended to the captcha ids, for example "captcha:".
+	KeyPrefix string
+	// Expiration is the time to live of the captchas, Expiration by
+	// default.
+	Expiration time.Duration
+	// PoolSize is the number of idle connections kept for reuse, 4 by
+	// default.
+	PoolSize int
+	// DialTimeout bounds connecting to the server, 5 seconds by default.
+	DialTimeout time.Duration
+}
+
+// errRedisClosed is returned by a RedisStore once it is closed.
+var errRedisClosed = errors.New("captcha: redis store closed")
+
+// redisError is an error reply of the server.
+type redisError string
+
+func (e redisError) Error() string {
+	return "captcha: redis: " + string(e)
+}
+
+// getDelScript consumes a key on servers older than Redis 6.2, which lack
+// GETDEL.
+const getDelScript = "local v = redis.call('GET', KEYS[1]) if v then redis.call('DEL', KEYS[1]) end return v"
+
+// RedisStore is a Store and StoreContext keeping the captchas in Redis. It
+// speaks RESP over plain TCP connections, so it needs no client library.
+//
+// Captchas expire with the native time to live of the keys, and are
+// consumed atomically with GETDEL, or with a Lua script on servers which do
+// not know GETDEL. Expired and used captchas, and the ones which used up
+// their attempts, are gone from the server, so CheckContext reports them as
+// ErrNotFound.
+type RedisStore struct {
+	opts   RedisOptions
+	policy AnswerPolicy
+
+	mu     sync.Mutex
+	idle   []*redisConn
+	closed bool
+
+	// noGetDel is set once the server refused GETDEL.
+	noGetDel atomic.Bool
+}
+
+// NewRedisStore returns a store using the Redis server of opts. It connects
+// lazily, use Ping to check the server at startup.
+func NewRedisStore(opts RedisOptions) *RedisStore {
+	if opts.Addr == "" {
+		opts.Addr = "localhost:6379"
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = Expiration
+	}
+	if opts.PoolSize <= 0 {
+		opts.PoolSize = 4
+	}
+	if opts.DialTimeout <= 0 {
+		opts.DialTimeout = 5 * time.Second
+	}
+	return &RedisStore{opts: opts}
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *RedisStore) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore.
+func (s *RedisStore) Expiration() time.Duration {
+	return s.opts.Expiration
+}
+
+// Ping checks that the server answers.
+func (s *RedisStore) Ping(ctx context.Context) error {
+	_, err := s.do(ctx, "PING")
+	return err
+}
+
+// Close closes the idle connections. Commands fail once the store is
+// closed.
+func (s *RedisStore) Close() error {
+	s.mu.Lock()
+	idle := s.idle
+	s.idle, s.closed = nil, true
+	s.mu.Unlock()
+	for _, c := range idle {
+		c.Close()
+	}
+	return nil
+}
+
+// Set sets the digits for the captcha id.
+func (s *RedisStore) Set(id string, value string) error {
+	return s.SetContext(context.Background(), id, value)
+}
+
+// Get returns stored digits for the captcha id, or an empty string if the
+// server could not be reached.
+func (s *RedisStore) Get(id string, clear bool) string {
+	v, _ := s.GetContext(context.Background(), id, clear)
+	return v
+}
+
+// Verify captcha's answer directly.
+func (s *RedisStore) Verify(id, answer string, clear bool) bool {
+	return s.CheckContext(context.Background(), id, answer, clear) == nil
+}
+
+// SetContext implements StoreContext.
+func (s *RedisStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.SetRecordContext(ctx, id, Record{Answer: value})
+}
+
+// SetRecordContext implements StoreContext.
+func (s *RedisStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	_, err := s.set(ctx, id, rec)
+	return err
+}
+
+// set saves rec for captcha id with the store expiration. The options are
+// appended to the SET command, its reply is nil if they prevented it.
+func (s *RedisStore) set(ctx context.Context, id string, rec Record, opts ...string) (interface{}, error) {
+	secs := int64((s.opts.Expiration + time.Second - 1) / time.Second)
+	stored := newStoredRecord(rec, time.Duration(secs)*time.Second)
+	args := append([]string{"SET", s.key(id), stored.encode(), "EX", strconv.FormatInt(secs, 10)}, opts...)
+	return s.do(ctx, args...)
+}
+
+// GetContext implements StoreContext.
+func (s *RedisStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	get := s.get
+	if clear {
+		get = s.take
+	}
+	rec, err := get(ctx, id)
+	if err != nil || rec == nil {
+		return "", err
+	}
+	return rec.Answer, nil
+}
+
+// VerifyContext implements StoreContext.
+func (s *RedisStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	err := s.CheckContext(ctx, id, answer, clear)
+	if err != nil && !isVerifyOutcome(err) {
+		return false, err
+	}
+	return err == nil, nil
+}
+
+// CheckContext implements StoreContext. A wrong answer to a captcha with an
+// attempt limit takes the record off the server while the failure is
+// counted, a concurrent verification sees ErrNotFound meanwhile.
+func (s *RedisStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	if answer == "" || !clear {
+		rec, err := s.get(ctx, id)
+		switch {
+		case err != nil:
+			return err
+		case rec == nil:
+			return ErrNotFound
+		}
+		reportRecordDriver(ctx, rec.Driver)
+		if answer == "" {
+			return ErrMismatch
+		}
+		failure := rec.match(ctx, s.policy, answer)
+		if failure == nil || rec.MaxAttempts == 0 {
+			return failure
+		}
+	}
+	// Take the record, so that it is consumed once, and failures are
+	// counted on its latest state.
+	rec, err := s.take(ctx, id)
+	switch {
+	case err != nil:
+		return err
+	case rec == nil:
+		return ErrNotFound
+	}
+	reportRecordDriver(ctx, rec.Driver)
+	failure := rec.match(ctx, s.policy, answer)
+	if failure != nil && rec.MaxAttempts > 0 {
+		// A wrong answer does not consume the captcha while it has
+		// attempts left.
+		if failure = rec.fail(failure); failure == ErrTooManyAttempts {
+			return failure
+		}
+	} else if clear {
+		return failure
+	}
+	if err := s.restore(ctx, id, rec); err != nil {
+		return err
+	}
+	return failure
+}
+
+// ReplaceRecordContext implements ReplaceStore. The record is saved with
+// SET XX, so a captcha consumed or invalidated after its binding was
+// checked is not revived.
+func (s *RedisStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	stored, err := s.get(ctx, id)
+	switch {
+	case err != nil:
+		return err
+	case stored == nil:
+		return ErrNotFound
+	case !bindingsEqual(stored.Binding, bindingFrom(ctx)):
+		return ErrBindingMismatch
+	}
+	reply, err := s.set(ctx, id, rec, "XX")
+	if err == nil && reply == nil {
+		err = ErrNotFound
+	}
+	return err
+}
+
+// key returns the key of captcha id.
+func (s *RedisStore) key(id string) string {
+	return s.opts.KeyPrefix + id
+}
+
+// get returns the record of captcha id, or nil if there is none.
+func (s *RedisStore) get(ctx context.Context, id string) (*storedRecord, error) {
+	reply, err := s.do(ctx, "GET", s.key(id))
+	return s.record(id, reply, err)
+}
+
+// take returns and deletes the record of captcha id, or nil if there is
+// none.
+func (s *RedisStore) take(ctx context.Context, id string) (*storedRecord, error) {
+	if !s.noGetDel.Load() {
+		reply, err := s.do(ctx, "GETDEL", s.key(id))
+		var rerr redisError
+		if !errors.As(err, &rerr) || !strings.HasPrefix(string(rerr), "ERR unknown command") {
+			return s.record(id, reply, err)
+		}
+		s.noGetDel.Store(true)
+	}
+	reply, err := s.do(ctx, "EVAL", getDelScript, "1", s.key(id))
+	return s.record(id, reply, err)
+}
+
+// record decodes the reply of GET or GETDEL for captcha id.
+func (s *RedisStore) record(id string, reply interface{}, err error) (*storedRecord, error) {
+	if err != nil || reply == nil {
+		return nil, err
+	}
+	v, ok := reply.(string)
+	if !ok {
+		return nil, fmt.Errorf("captcha: redis: unexpected reply %v", reply)
+	}
+	return decodeStoredRecord(id, []byte(v))
+}
+
+// restore saves back a record taken from the server, for the rest of its
+// time to live, unless the captcha was stored again meanwhile.
+func (s *RedisStore) restore(ctx context.Context, id string, rec *storedRecord) error {
+	ttl := rec.ttl(time.Now())
+	if ttl < time.Millisecond {
+		return nil
+	}
+	_, err := s.do(ctx, "SET", s.key(id), rec.encode(), "PX", strconv.FormatInt(ttl.Milliseconds(), 10), "NX")
+	return err
+}
+
+// do sends a command and returns its reply. An error reply of the server is
+// returned as a redisError. A command sent on an idle connection which the
+// server closed meanwhile is sent again on a new connection.
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	for {
+		c, pooled, err := s.conn(ctx)
+		if err != nil {
+			return nil, err
+		}
+		reply, err := c.exec(ctx, args)
+		var rerr redisError
+		if err == nil || errors.As(err, &rerr) {
+			s.put(c)
+			return reply, err
+		}
+		if err = closeConn(ctx, c, err); pooled && isConnReset(err) {
+			continue
+		}
+		return nil, err
+	}
+}
+
+// conn returns an idle connection, or a new one. It reports whether the
+// connection was idle.
+func (s *RedisStore) conn(ctx context.Context) (*redisConn, bool, error) {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		return nil, false, errRedisClosed
+	}
+	if n := len(s.idle); n > 0 {
+		c := s.idle[n-1]
+		s.idle = s.idle[:n-1]
+		s.mu.Unlock()
+		return c, true, nil
+	}
+	s.mu.Unlock()
+	c, err := s.dial(ctx)
+	return c, false, err
+}
+
+// put keeps a connection for reuse, or closes it if the pool is full.
+func (s *RedisStore) put(c *redisConn) {
+	s.mu.Lock()
+	if !s.closed && len(s.idle) < s.opts.PoolSize {
+		s.idle = append(s.idle, c)
+		c = nil
+	}
+	s.mu.Unlock()
+	if c != nil {
+		c.Close()
+	}
+}
+
+// dial connects to the server, authenticates and selects the database.
+func (s *RedisStore) dial(ctx context.Context) (*redisConn, error) {
+	d := net.Dialer{Timeout: s.opts.DialTimeout}
+	nc, err := d.DialContext(ctx, "tcp", s.opts.Addr)
+	if err != nil {
+		return nil, err
+	}
+	c := newRedisConn(nc)
+	if s.opts.Password != "" {
+		args := []string{"AUTH", s.opts.Password}
+		if s.opts.Username != "" {
+			args = []string{"AUTH", s.opts.Username, s.opts.Password}
+		}
+		if _, err := c.exec(ctx, args); err != nil {
+			return nil, closeConn(ctx, c, err)
+		}
+	}
+	if s.opts.DB != 0 {
+		if _, err := c.exec(ctx, []string{"SELECT", strconv.Itoa(s.opts.DB)}); err != nil {
+			return nil, closeConn(ctx, c, err)
+		}
+	}
+	return c, nil
+}
+
+// redisConn is a connection speaking RESP.
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+func newRedisConn(c net.Conn) *redisConn {
+	return &redisConn{Conn: c, r: bufio.NewReader(c), w: bufio.NewWriter(c)}
+}
+
+// exec sends a command and reads its reply, within the deadline of ctx. If
+// ctx is done first the connection is broken and must be closed.
+func (c *redisConn) exec(ctx context.Context, args []string) (reply interface{}, err error) {
+	err = connDo(ctx, c, func() error {
+		if err := c.writeCommand(args); err != nil {
+			return err
+		}
+		reply, err = c.readReply()
+		return err
+	})
+	return reply, err
+}
+
+// writeCommand writes a command as an array of bulk strings.
+func (c *redisConn) writeCommand(args []string) error {
+	c.w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n")
+	for _, a := range args {
+		c.w.WriteString("$" + strconv.Itoa(len(a)) + "\r\n")
+		c.w.WriteString(a)
+		c.w.WriteString("\r\n")
+	}
+	return c.w.Flush()
+}
+
+// readReply reads a reply. Simple and bulk strings are returned as strings,
+// integers as int64, arrays as []interface{} and null replies as nil.
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("captcha: redis: malformed reply %q", line)
+	}
+	kind, line := line[0], line[1:len(line)-2]
+	switch kind {
+	case '+':
+		return line, nil
+	case '-':
+		return nil, redisError(line)
+	case ':':
+		return strconv.ParseInt(line, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		b := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, b); err != nil {
+			return nil, err
+		}
+		return string(b[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line)
+		if err != nil || n < 0 {
+			return nil, err
+		}
+		a := make([]interface{}, n)
+		for i := range a {
+			if a[i], err = c.readReply(); err != nil {
+				return nil, err
+			}
+		}
+		return a, nil
+	}
+	return nil, fmt.Errorf("captcha: redis: malformed reply %q", line)
+}
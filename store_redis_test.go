@@ -0,0 +1,349 @@
+package base64Captcha
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRedis is an in-process server speaking enough RESP for RedisStore.
+type fakeRedis struct {
+	ln       net.Listener
+	password string
+	// noGetDel makes the server refuse GETDEL, like Redis before 6.2.
+	noGetDel bool
+	// stall makes the server read commands without replying.
+	stall bool
+
+	mu       sync.Mutex
+	data     map[string]fakeRedisValue
+	commands []string
+	dials    int
+	conns    []net.Conn
+}
+
+type fakeRedisValue struct {
+	value   string
+	expires time.Time
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeRedis{ln: ln, data: make(map[string]fakeRedisValue)}
+	go f.serve()
+	t.Cleanup(f.close)
+	return f
+}
+
+func (f *fakeRedis) addr() string {
+	return f.ln.Addr().String()
+}
+
+func (f *fakeRedis) close() {
+	f.ln.Close()
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+}
+
+// dropConns closes the connections, as a server timing out idle clients.
+func (f *fakeRedis) dropConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, c := range f.conns {
+		c.Close()
+	}
+	f.conns = nil
+}
+
+// sent returns the commands received, with their arguments.
+func (f *fakeRedis) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.commands...)
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		c, err := f.ln.Accept()
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		f.dials++
+		f.conns = append(f.conns, c)
+		f.mu.Unlock()
+		go f.handle(c)
+	}
+}
+
+func (f *fakeRedis) handle(nc net.Conn) {
+	defer nc.Close()
+	c := newRedisConn(nc)
+	for {
+		req, err := c.readReply()
+		if err != nil {
+			return
+		}
+		var args []string
+		for _, a := range req.([]interface{}) {
+			args = append(args, a.(string))
+		}
+		if f.stall {
+			continue
+		}
+		c.w.WriteString(f.exec(args))
+		if c.w.Flush() != nil {
+			return
+		}
+	}
+}
+
+// exec runs a command and returns the encoded reply.
+func (f *fakeRedis) exec(args []string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commands = append(f.commands, strings.Join(args, " "))
+	get := func(key string) (string, bool) {
+		v, ok := f.data[key]
+		if ok && time.Now().After(v.expires) {
+			delete(f.data, key)
+			ok = false
+		}
+		return v.value, ok
+	}
+	bulk := func(v string, ok bool) string {
+		if !ok {
+			return "$-1\r\n"
+		}
+		return "$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n"
+	}
+	switch cmd := strings.ToUpper(args[0]); {
+	case cmd == "AUTH":
+		if args[len(args)-1] != f.password {
+			return "-WRONGPASS invalid username-password pair\r\n"
+		}
+		return "+OK\r\n"
+	case cmd == "PING":
+		return "+PONG\r\n"
+	case cmd == "SELECT":
+		return "+OK\r\n"
+	case cmd == "SET":
+		key, ttl, nx := args[1], time.Duration(0), false
+		for i := 3; i < len(args); i++ {
+			switch strings.ToUpper(args[i]) {
+			case "EX", "PX":
+				n, _ := strconv.Atoi(args[i+1])
+				ttl = time.Duration(n) * time.Millisecond
+				if strings.ToUpper(args[i]) == "EX" {
+					ttl = time.Duration(n) * time.Second
+				}
+				i++
+			case "NX":
+				nx = true
+			}
+		}
+		if _, ok := get(key); ok && nx {
+			return "$-1\r\n"
+		}
+		f.data[key] = fakeRedisValue{args[2], time.Now().Add(ttl)}
+		return "+OK\r\n"
+	case cmd == "GET":
+		return bulk(get(args[1]))
+	case cmd == "GETDEL" && !f.noGetDel, cmd == "EVAL" && args[1] == getDelScript:
+		key := args[len(args)-1]
+		v, ok := get(key)
+		delete(f.data, key)
+		return bulk(v, ok)
+	}
+	return "-ERR unknown command '" + args[0] + "', with args beginning with: \r\n"
+}
+
+func TestRedisStore(t *testing.T) {
+	f := newFakeRedis(t)
+	f.password = "secret"
+	s := NewRedisStore(RedisOptions{Addr: f.addr(), Password: "secret", DB: 2, KeyPrefix: "captcha:", Expiration: 90 * time.Second})
+	defer s.Close()
+	if err := s.Ping(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Set("id", "AbC12"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get("id", false); v != "AbC12" {
+		t.Errorf("Get() = %q", v)
+	}
+	if s.Verify("id", "abc13", false) {
+		t.Error("wrong answer verified")
+	}
+	if !s.Verify("id", " abc12 ", true) {
+		t.Error("right answer failed")
+	}
+	if s.Verify("id", "abc12", true) {
+		t.Error("cleared captcha verified")
+	}
+
+	s.Set("other", "1234")
+	if v := s.Get("other", true); v != "1234" {
+		t.Errorf("Get() = %q", v)
+	}
+	if v := s.Get("other", false); v != "" {
+		t.Errorf("Get() after clear = %q", v)
+	}
+
+	sent := f.sent()
+	if sent[0] != "AUTH secret" || sent[1] != "SELECT 2" {
+		t.Errorf("connection setup = %q", sent[:2])
+	}
+	if !strings.HasPrefix(sent[3], "SET captcha:id ") || !strings.HasSuffix(sent[3], " EX 90") {
+		t.Errorf("Set sent %q", sent[3])
+	}
+	if f.dials != 1 {
+		t.Errorf("%d connections dialed, want 1", f.dials)
+	}
+}
+
+func TestRedisStore_Check(t *testing.T) {
+	f := newFakeRedis(t)
+	s := NewRedisStore(RedisOptions{Addr: f.addr()})
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.CheckContext(ctx, "missing", "1234", true); err != ErrNotFound {
+		t.Errorf("CheckContext() of a missing captcha = %v", err)
+	}
+	s.SetRecordContext(ctx, "limited", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "limited", "", true); err != ErrMismatch {
+		t.Errorf("CheckContext() of an empty answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", true); err != ErrMismatch {
+		t.Errorf("first wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", false); err != ErrTooManyAttempts {
+		t.Errorf("second wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "1234", true); err != ErrNotFound {
+		t.Errorf("right answer after too many attempts = %v", err)
+	}
+
+	s.SetRecordContext(ctx, "typo", Record{Answer: "1234", MaxAttempts: 3})
+	s.CheckContext(ctx, "typo", "1243", true)
+	if err := s.CheckContext(ctx, "typo", "1234", false); err != nil {
+		t.Errorf("right answer after a typo = %v", err)
+	}
+	if err := s.CheckContext(ctx, "typo", "1234", true); err != nil {
+		t.Errorf("right answer kept = %v", err)
+	}
+	if err := s.CheckContext(ctx, "typo", "1234", true); err != ErrNotFound {
+		t.Errorf("consumed captcha = %v", err)
+	}
+
+	s.SetRecordContext(ctx, "bound", Record{Answer: "1234", Binding: "sess"})
+	if err := s.CheckContext(ctx, "bound", "1234", false); err != ErrBindingMismatch {
+		t.Errorf("CheckContext() without binding = %v", err)
+	}
+	sess := ContextWithBinding(ctx, "sess")
+	if err := s.ReplaceRecordContext(ctx, "bound", Record{Answer: "5678"}); err != ErrBindingMismatch {
+		t.Errorf("ReplaceRecordContext() without binding = %v", err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "5678", Binding: "sess"}); err != nil {
+		t.Errorf("ReplaceRecordContext() with binding = %v", err)
+	}
+	if ok, err := s.VerifyContext(sess, "bound", "5678", true); !ok || err != nil {
+		t.Errorf("VerifyContext() of the new answer = %v, %v", ok, err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "9999", Binding: "sess"}); err != ErrNotFound {
+		t.Errorf("ReplaceRecordContext() of a used captcha = %v", err)
+	}
+	if ok, _ := s.VerifyContext(sess, "bound", "9999", true); ok {
+		t.Error("ReplaceRecordContext() revived a used captcha")
+	}
+}
+
+func TestRedisStore_getDelFallback(t *testing.T) {
+	f := newFakeRedis(t)
+	f.noGetDel = true
+	s := NewRedisStore(RedisOptions{Addr: f.addr()})
+	defer s.Close()
+	for i := 0; i < 2; i++ {
+		s.Set("id", "1234")
+		if !s.Verify("id", "1234", true) || s.Verify("id", "1234", true) {
+			t.Fatal("captcha not consumed once")
+		}
+	}
+	var getdel, eval int
+	for _, cmd := range f.sent() {
+		switch strings.Fields(cmd)[0] {
+		case "GETDEL":
+			getdel++
+		case "EVAL":
+			eval++
+		}
+	}
+	if getdel != 1 || eval != 4 {
+		t.Errorf("sent %d GETDEL and %d EVAL, want 1 and 4", getdel, eval)
+	}
+}
+
+func TestRedisStore_errors(t *testing.T) {
+	f := newFakeRedis(t)
+	f.password = "secret"
+	s := NewRedisStore(RedisOptions{Addr: f.addr(), Password: "wrong"})
+	var rerr redisError
+	if err := s.Set("id", "1234"); !errors.As(err, &rerr) {
+		t.Errorf("Set() with a wrong password = %v", err)
+	}
+
+	s = NewRedisStore(RedisOptions{Addr: f.addr(), Password: "secret"})
+	s.Set("id", "1234")
+	f.dropConns()
+	if v := s.Get("id", false); v != "1234" {
+		t.Errorf("Get() after the server closed the connection = %q", v)
+	}
+	s.Close()
+	if err := s.Set("id", "1234"); err != errRedisClosed {
+		t.Errorf("Set() after Close = %v", err)
+	}
+
+	f.stall = true
+	s = NewRedisStore(RedisOptions{Addr: f.addr(), Password: "secret"})
+	defer s.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := s.GetContext(ctx, "id", false); err != context.DeadlineExceeded {
+		t.Errorf("GetContext() of a stalled server = %v", err)
+	}
+	ctx, cancel = context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := s.VerifyContext(ctx, "id", "1234", true); err != context.Canceled {
+		t.Errorf("VerifyContext() canceled = %v", err)
+	}
+}
+
+func TestRedisStore_Captcha(t *testing.T) {
+	f := newFakeRedis(t)
+	s := NewRedisStore(RedisOptions{Addr: f.addr(), KeyPrefix: "captcha:"})
+	defer s.Close()
+	c := NewCaptcha(DefaultDriverDigit, s)
+	res, err := c.GenerateResult()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.ExpiresAt.IsZero() {
+		t.Error("ExpiresAt not set")
+	}
+	if !c.Verify(res.ID, res.Answer, true) {
+		t.Error("right answer failed")
+	}
+}
@@ -0,0 +1,483 @@
+package base64Captcha
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SQLDialect is the SQL flavor of the database of a SQLStore.
+type SQLDialect int
+
+const (
+	// DialectSQLite is SQLite, with ? placeholders.
+	DialectSQLite SQLDialect = iota
+	// DialectPostgres is PostgreSQL, with $1 placeholders.
+	DialectPostgres
+	// DialectMySQL is MySQL and MariaDB, with ? placeholders.
+	DialectMySQL
+)
+
+// rebind rewrites the ? placeholders of query for the dialect.
+func (d SQLDialect) rebind(query string) string {
+	if d != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// forUpdate returns the clause locking the rows read in a transaction.
+// SQLite locks the whole database instead.
+func (d SQLDialect) forUpdate() string {
+	if d == DialectSQLite {
+		return ""
+	}
+	return " FOR UPDATE"
+}
+
+// SQLOptions configure a SQLStore.
+type SQLOptions struct {
+	// Dialect is the SQL flavor of the database.
+	Dialect SQLDialect
+	// Table is the name of the captchas table, "captchas" by default. The
+	// applied migrations are recorded in Table + "_migrations".
+	Table string
+	// Key is the secret key of the HMAC of the answers. It must be the same
+	// on every instance sharing the database.
+	Key []byte
+	// Expiration is the time to live of the captchas, Expiration by
+	// default.
+	Expiration time.Duration
+	// PurgeInterval is how often expired rows are deleted, Expiration by
+	// default. A negative interval disables the purge, call Purge instead.
+	PurgeInterval time.Duration
+}
+
+// sqlMigrations create and update the schema, in order. The table name
+// replaces {table}. Times are Unix milliseconds.
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE {table} (
+	id VARCHAR(128) NOT NULL PRIMARY KEY,
+	answer_hash VARCHAR(64) NOT NULL,
+	binding_hash VARCHAR(64) NOT NULL,
+	created_at BIGINT NOT NULL,
+	expires_at BIGINT NOT NULL,
+	attempts INTEGER NOT NULL,
+	max_attempts INTEGER NOT NULL,
+	used SMALLINT NOT NULL
+)`,
+		`CREATE INDEX {table}_expires_at ON {table} (expires_at)`,
+	},
+	{
+		`ALTER TABLE {table} ADD COLUMN driver VARCHAR(64) NOT NULL DEFAULT ''`,
+	},
+}
+
+// SQLStore is a Store and StoreContext keeping the captchas in a table of a
+// SQL database, through database/sql. NewSQLStore creates the table.
+//
+// Like NewHashStore, it keeps keyed hashes of the normalized answers and of
+// the bindings rather than the plaintext, so Get returns the hash of the
+// answer, and only the Normalizer of the answer policy applies.
+//
+// Verifications read and update the row in a transaction, with an update
+// conditioned on the row read, so two concurrent verifications cannot both
+// consume a captcha. Used captchas are kept until they are purged, so that
+// CheckContext reports a replay as ErrAlreadyUsed.
+type SQLStore struct {
+	db     *sql.DB
+	opts   SQLOptions
+	policy AnswerPolicy
+
+	queries struct {
+		insert, delete, selectRow, update, purge, selectExpired string
+	}
+	expireHook
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewSQLStore returns a store keeping the captchas in db, after applying
+// the migrations of its table. It starts the periodic purge, which Close
+// stops. The database is not closed by the store.
+func NewSQLStore(db *sql.DB, opts SQLOptions) (*SQLStore, error) {
+	if opts.Table == "" {
+		opts.Table = "captchas"
+	}
+	if !isSQLIdentifier(opts.Table) {
+		return nil, fmt.Errorf("captcha: invalid table name %q", opts.Table)
+	}
+	if len(opts.Key) == 0 {
+		return nil, errors.New("captcha: SQLStore needs a key")
+	}
+	if opts.Expiration <= 0 {
+		opts.Expiration = Expiration
+	}
+	if opts.PurgeInterval == 0 {
+		opts.PurgeInterval = opts.Expiration
+	}
+	opts.Key = append([]byte(nil), opts.Key...)
+	s := &SQLStore{db: db, opts: opts, stop: make(chan struct{})}
+	q := func(query string) string {
+		return opts.Dialect.rebind(strings.ReplaceAll(query, "{table}", opts.Table))
+	}
+	s.queries.insert = q(`INSERT INTO {table} (id, answer_hash, binding_hash, created_at, expires_at, attempts, max_attempts, used, driver) VALUES (?, ?, ?, ?, ?, 0, ?, 0, ?)`)
+	s.queries.delete = q(`DELETE FROM {table} WHERE id = ?`)
+	s.queries.selectRow = q(`SELECT answer_hash, binding_hash, expires_at, attempts, max_attempts, used, driver FROM {table} WHERE id = ?` + opts.Dialect.forUpdate())
+	s.queries.update = q(`UPDATE {table} SET answer_hash = ?, binding_hash = ?, expires_at = ?, attempts = ?, max_attempts = ?, used = ?, driver = ? WHERE id = ? AND answer_hash = ? AND attempts = ? AND used = 0`)
+	s.queries.purge = q(`DELETE FROM {table} WHERE expires_at < ?`)
+	s.queries.selectExpired = q(`SELECT driver FROM {table} WHERE expires_at < ? AND used = 0` + opts.Dialect.forUpdate())
+	if err := s.Migrate(context.Background()); err != nil {
+		return nil, err
+	}
+	if opts.PurgeInterval > 0 {
+		s.wg.Add(1)
+		go s.purgeLoop()
+	}
+	return s, nil
+}
+
+// isSQLIdentifier reports whether name can be used unquoted as a table
+// name.
+func isSQLIdentifier(name string) bool {
+	for i, r := range name {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case '0' <= r && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Migrate applies the migrations which the database lacks. NewSQLStore
+// calls it, instances sharing a database should not be started at the
+// same time on a fresh database.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	table := s.opts.Table + "_migrations"
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS `+table+` (version INTEGER NOT NULL PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("captcha: creating %s: %w", table, err)
+	}
+	var version int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM `+table).Scan(&version); err != nil {
+		return fmt.Errorf("captcha: reading %s: %w", table, err)
+	}
+	for ; version < len(sqlMigrations); version++ {
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, stmt := range sqlMigrations[version] {
+			if _, err = tx.ExecContext(ctx, strings.ReplaceAll(stmt, "{table}", s.opts.Table)); err != nil {
+				break
+			}
+		}
+		if err == nil {
+			_, err = tx.ExecContext(ctx, s.opts.Dialect.rebind(`INSERT INTO `+table+` (version) VALUES (?)`), version+1)
+		}
+		if err == nil {
+			err = tx.Commit()
+		} else {
+			tx.Rollback()
+		}
+		if err != nil {
+			return fmt.Errorf("captcha: migrating %s to version %d: %w", s.opts.Table, version+1, err)
+		}
+	}
+	return nil
+}
+
+// Purge deletes the expired captchas and returns how many there were. If a
+// function is registered with OnExpire, it is called for those which were
+// never used once they are deleted.
+func (s *SQLStore) Purge(ctx context.Context) (int64, error) {
+	now := time.Now().UnixMilli()
+	fn := s.expireFunc()
+	if fn == nil {
+		res, err := s.db.ExecContext(ctx, s.queries.purge, now)
+		if err != nil {
+			return 0, err
+		}
+		return res.RowsAffected()
+	}
+	n, drivers, err := s.purgeTx(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+	for _, driver := range drivers {
+		fn(ExpireEvent{Driver: driver})
+	}
+	return n, nil
+}
+
+// purgeTx deletes the captchas expired at now in a transaction, and returns
+// how many there were and the drivers of those which were never used.
+func (s *SQLStore) purgeTx(ctx context.Context, now int64) (n int64, drivers []string, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+	rows, err := tx.QueryContext(ctx, s.queries.selectExpired, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var driver string
+		if err := rows.Scan(&driver); err != nil {
+			return 0, nil, err
+		}
+		drivers = append(drivers, driver)
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+	res, err := tx.ExecContext(ctx, s.queries.purge, now)
+	if err != nil {
+		return 0, nil, err
+	}
+	if n, err = res.RowsAffected(); err != nil {
+		return 0, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, nil, err
+	}
+	return n, drivers, nil
+}
+
+// purgeLoop purges the expired captchas until the store is closed. Failed
+// purges are retried at the next interval.
+func (s *SQLStore) purgeLoop() {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.opts.PurgeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Purge(context.Background())
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Close stops the periodic purge.
+func (s *SQLStore) Close() error {
+	s.stopOnce.Do(func() {
+		close(s.stop)
+	})
+	s.wg.Wait()
+	return nil
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *SQLStore) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore.
+func (s *SQLStore) Expiration() time.Duration {
+	return s.opts.Expiration
+}
+
+// Set sets the digits for the captcha id.
+func (s *SQLStore) Set(id string, value string) error {
+	return s.SetContext(context.Background(), id, value)
+}
+
+// Get returns the stored hash of the answer for the captcha id.
+func (s *SQLStore) Get(id string, clear bool) string {
+	v, _ := s.GetContext(context.Background(), id, clear)
+	return v
+}
+
+// Verify hashes the answer and verifies it against the stored hash.
+func (s *SQLStore) Verify(id, answer string, clear bool) bool {
+	return s.CheckContext(context.Background(), id, answer, clear) == nil
+}
+
+// SetContext implements StoreContext.
+func (s *SQLStore) SetContext(ctx context.Context, id string, value string) error {
+	return s.SetRecordContext(ctx, id, Record{Answer: value})
+}
+
+// SetRecordContext implements StoreContext.
+func (s *SQLStore) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	now := time.Now()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, s.queries.delete, id); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, s.queries.insert, id,
+		keyedDigest(s.opts.Key, id, answerPolicyFrom(ctx, s.policy).Normalize(rec.Answer)),
+		s.bindingDigest(id, rec.Binding),
+		now.UnixMilli(), now.Add(s.opts.Expiration).UnixMilli(), rec.MaxAttempts, rec.Driver)
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetContext implements StoreContext.
+func (s *SQLStore) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	var hash string
+	err := s.update(ctx, id, func(rec *storedRecord) (bool, error) {
+		hash = ""
+		if rec.Used || rec.exhausted() || rec.ttl(time.Now()) <= 0 {
+			return false, nil
+		}
+		hash = rec.Answer
+		if clear {
+			rec.consume()
+		}
+		return clear, nil
+	})
+	if err == ErrNotFound {
+		err = nil
+	}
+	return hash, err
+}
+
+// VerifyContext implements StoreContext.
+func (s *SQLStore) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	err := s.CheckContext(ctx, id, answer, clear)
+	if err != nil && !isVerifyOutcome(err) {
+		return false, err
+	}
+	return err == nil, nil
+}
+
+// CheckContext implements StoreContext.
+func (s *SQLStore) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	digest := keyedDigest(s.opts.Key, id, answerPolicyFrom(ctx, s.policy).Normalize(answer))
+	// The record holds hashes, compare them as they are.
+	hashed := ContextWithBinding(withAnswerPolicy(ctx, exactAnswerPolicy), s.bindingDigest(id, bindingFrom(ctx)))
+	return s.update(ctx, id, func(rec *storedRecord) (bool, error) {
+		reportRecordDriver(ctx, rec.Driver)
+		if err := rec.live(time.Now()); err != nil {
+			return false, err
+		}
+		if answer == "" {
+			return false, ErrMismatch
+		}
+		failure := rec.match(hashed, AnswerPolicy{}, digest)
+		if failure != nil && rec.MaxAttempts > 0 {
+			return true, rec.fail(failure)
+		}
+		if clear {
+			rec.consume()
+		}
+		return clear, failure
+	})
+}
+
+// ReplaceRecordContext implements ReplaceStore.
+func (s *SQLStore) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	if id == "" {
+		return ErrNotFound
+	}
+	binding := s.bindingDigest(id, bindingFrom(ctx))
+	rec.Answer = keyedDigest(s.opts.Key, id, answerPolicyFrom(ctx, s.policy).Normalize(rec.Answer))
+	rec.Binding = s.bindingDigest(id, rec.Binding)
+	return s.update(ctx, id, func(stored *storedRecord) (bool, error) {
+		if err := stored.live(time.Now()); err != nil {
+			return false, err
+		}
+		if !bindingsEqual(stored.Binding, binding) {
+			return false, ErrBindingMismatch
+		}
+		*stored = *newStoredRecord(rec, s.opts.Expiration)
+		return true, nil
+	})
+}
+
+// bindingDigest returns the keyed hash of the binding of captcha id.
+func (s *SQLStore) bindingDigest(id, binding string) string {
+	return keyedDigest(s.opts.Key, "binding:"+id, binding)
+}
+
+// update reads the row of captcha id in a transaction and passes it to fn.
+// If fn changed the record the row is updated, unless another transaction
+// changed it meanwhile, in which case fn runs again on the latest row. It
+// returns the error of fn, or ErrNotFound if there is no row.
+func (s *SQLStore) update(ctx context.Context, id string, fn func(rec *storedRecord) (save bool, err error)) error {
+	for {
+		done, result, err := s.updateTx(ctx, id, fn)
+		if err != nil {
+			return err
+		}
+		if done {
+			return result
+		}
+	}
+}
+
+// updateTx runs one transaction of update. done is false if the row
+// changed before it could be updated.
+func (s *SQLStore) updateTx(ctx context.Context, id string, fn func(rec *storedRecord) (bool, error)) (done bool, result, err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	defer tx.Rollback()
+	var (
+		rec  storedRecord
+		used int
+	)
+	err = tx.QueryRowContext(ctx, s.queries.selectRow, id).Scan(&rec.Answer, &rec.Binding, &rec.Deadline, &rec.Failures, &rec.MaxAttempts, &used, &rec.Driver)
+	if err == sql.ErrNoRows {
+		return true, ErrNotFound, nil
+	}
+	if err != nil {
+		return false, nil, err
+	}
+	rec.Used = used != 0
+	answer, attempts := rec.Answer, rec.Failures
+	save, result := fn(&rec)
+	if !save {
+		return true, result, nil
+	}
+	used = 0
+	if rec.Used {
+		used = 1
+	}
+	res, err := tx.ExecContext(ctx, s.queries.update, rec.Answer, rec.Binding, rec.Deadline, rec.Failures, rec.MaxAttempts, used, rec.Driver, id, answer, attempts)
+	if err != nil {
+		return false, nil, err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return false, nil, err
+	}
+	return n == 1, result, nil
+}
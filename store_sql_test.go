@@ -0,0 +1,459 @@
+package base64Captcha
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func init() {
+	sql.Register("captchatest", fakeSQLDriver{})
+}
+
+// fakeSQLDriver is a database/sql driver understanding the queries of
+// SQLStore, over an in-memory table per data source name. Transactions
+// hold the lock of the database until they end.
+type fakeSQLDriver struct{}
+
+var (
+	fakeSQLDBsMu sync.Mutex
+	fakeSQLDBs   = make(map[string]*fakeSQLDB)
+)
+
+type fakeSQLDB struct {
+	lock sync.Mutex // held by statements and transactions
+
+	mu      sync.Mutex // guards queries
+	queries []string
+
+	version int
+	created bool
+	rows    map[string][]driver.Value
+}
+
+// openFakeSQL returns a database of the fake driver and its state.
+func openFakeSQL(t *testing.T) (*sql.DB, *fakeSQLDB) {
+	db, err := sql.Open("captchatest", t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	fakeSQLDBsMu.Lock()
+	defer fakeSQLDBsMu.Unlock()
+	f := &fakeSQLDB{rows: make(map[string][]driver.Value)}
+	fakeSQLDBs[t.Name()] = f
+	return db, f
+}
+
+// sent returns the queries received.
+func (f *fakeSQLDB) sent() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.queries...)
+}
+
+func (fakeSQLDriver) Open(name string) (driver.Conn, error) {
+	fakeSQLDBsMu.Lock()
+	defer fakeSQLDBsMu.Unlock()
+	f, ok := fakeSQLDBs[name]
+	if !ok {
+		return nil, fmt.Errorf("no database %q", name)
+	}
+	return &fakeSQLConn{db: f}, nil
+}
+
+type fakeSQLConn struct {
+	db *fakeSQLDB
+	// tx is the transaction in progress, holding a copy of the rows to
+	// restore on rollback.
+	tx *fakeSQLTx
+}
+
+type fakeSQLTx struct {
+	c       *fakeSQLConn
+	rows    map[string][]driver.Value
+	version int
+	created bool
+}
+
+func (c *fakeSQLConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeSQLStmt{c: c, query: query}, nil
+}
+
+func (c *fakeSQLConn) Close() error {
+	return nil
+}
+
+func (c *fakeSQLConn) Begin() (driver.Tx, error) {
+	c.db.lock.Lock()
+	rows := make(map[string][]driver.Value, len(c.db.rows))
+	for id, row := range c.db.rows {
+		rows[id] = append([]driver.Value(nil), row...)
+	}
+	c.tx = &fakeSQLTx{c: c, rows: rows, version: c.db.version, created: c.db.created}
+	return c.tx, nil
+}
+
+func (tx *fakeSQLTx) Commit() error {
+	tx.end()
+	return nil
+}
+
+func (tx *fakeSQLTx) Rollback() error {
+	tx.c.db.rows, tx.c.db.version, tx.c.db.created = tx.rows, tx.version, tx.created
+	tx.end()
+	return nil
+}
+
+func (tx *fakeSQLTx) end() {
+	tx.c.tx = nil
+	tx.c.db.lock.Unlock()
+}
+
+type fakeSQLStmt struct {
+	c     *fakeSQLConn
+	query string
+}
+
+func (s *fakeSQLStmt) Close() error {
+	return nil
+}
+
+func (s *fakeSQLStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeSQLStmt) Exec(args []driver.Value) (driver.Result, error) {
+	_, n, err := s.run(args)
+	return driver.RowsAffected(n), err
+}
+
+func (s *fakeSQLStmt) Query(args []driver.Value) (driver.Rows, error) {
+	rows, _, err := s.run(args)
+	return &fakeSQLRows{rows: rows}, err
+}
+
+// postgresPlaceholder matches the placeholders of PostgreSQL.
+var postgresPlaceholder = regexp.MustCompile(`\$\d+`)
+
+// run runs the query and returns its rows and the number of rows affected.
+func (s *fakeSQLStmt) run(args []driver.Value) ([][]driver.Value, int64, error) {
+	f := s.c.db
+	if s.c.tx == nil {
+		f.lock.Lock()
+		defer f.lock.Unlock()
+	}
+	f.mu.Lock()
+	f.queries = append(f.queries, s.query)
+	f.mu.Unlock()
+	q := postgresPlaceholder.ReplaceAllString(s.query, "?")
+	if n := strings.Count(q, "?"); n != len(args) {
+		return nil, 0, fmt.Errorf("%d placeholders for %d arguments", n, len(args))
+	}
+	switch {
+	case strings.HasPrefix(q, "CREATE TABLE IF NOT EXISTS"):
+	case strings.HasPrefix(q, "SELECT COALESCE(MAX(version), 0)"):
+		return [][]driver.Value{{int64(f.version)}}, 0, nil
+	case strings.HasPrefix(q, "CREATE TABLE"):
+		if f.created {
+			return nil, 0, errors.New("table already exists")
+		}
+		f.created = true
+	case strings.HasPrefix(q, "CREATE INDEX"):
+	case strings.HasPrefix(q, "ALTER TABLE") && strings.HasSuffix(q, "ADD COLUMN driver VARCHAR(64) NOT NULL DEFAULT ''"):
+		for id, row := range f.rows {
+			f.rows[id] = append(row, "")
+		}
+	case strings.HasPrefix(q, "INSERT INTO") && strings.Contains(q, "_migrations"):
+		f.version = int(args[0].(int64))
+	case strings.HasPrefix(q, "INSERT INTO"):
+		id := args[0].(string)
+		if _, ok := f.rows[id]; ok {
+			return nil, 0, errors.New("duplicate key")
+		}
+		f.rows[id] = []driver.Value{args[0], args[1], args[2], args[3], args[4], int64(0), args[5], int64(0), args[6]}
+		return nil, 1, nil
+	case strings.HasPrefix(q, "SELECT answer_hash"):
+		if row, ok := f.rows[args[0].(string)]; ok {
+			return [][]driver.Value{{row[1], row[2], row[4], row[5], row[6], row[7], row[8]}}, 0, nil
+		}
+		return nil, 0, nil
+	case strings.HasPrefix(q, "SELECT driver") && strings.Contains(q, "WHERE expires_at < ? AND used = 0"):
+		var rows [][]driver.Value
+		for _, row := range f.rows {
+			if row[4].(int64) < args[0].(int64) && row[7] == int64(0) {
+				rows = append(rows, []driver.Value{row[8]})
+			}
+		}
+		return rows, 0, nil
+	case strings.HasPrefix(q, "UPDATE"):
+		row, ok := f.rows[args[7].(string)]
+		if !ok || row[1] != args[8] || row[5] != args[9] || row[7] != int64(0) {
+			return nil, 0, nil
+		}
+		row[1], row[2], row[4], row[5], row[6], row[7], row[8] = args[0], args[1], args[2], args[3], args[4], args[5], args[6]
+		return nil, 1, nil
+	case strings.HasSuffix(q, "WHERE id = ?"):
+		if _, ok := f.rows[args[0].(string)]; ok {
+			delete(f.rows, args[0].(string))
+			return nil, 1, nil
+		}
+	case strings.HasSuffix(q, "WHERE expires_at < ?"):
+		var n int64
+		for id, row := range f.rows {
+			if row[4].(int64) < args[0].(int64) {
+				delete(f.rows, id)
+				n++
+			}
+		}
+		return nil, n, nil
+	default:
+		return nil, 0, fmt.Errorf("unexpected query %q", s.query)
+	}
+	return nil, 0, nil
+}
+
+type fakeSQLRows struct {
+	rows [][]driver.Value
+}
+
+func (r *fakeSQLRows) Columns() []string {
+	if len(r.rows) == 0 {
+		return nil
+	}
+	return make([]string, len(r.rows[0]))
+}
+
+func (r *fakeSQLRows) Close() error {
+	return nil
+}
+
+func (r *fakeSQLRows) Next(dest []driver.Value) error {
+	if len(r.rows) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.rows[0])
+	r.rows = r.rows[1:]
+	return nil
+}
+
+func TestSQLStore(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		dialect     SQLDialect
+		placeholder string
+		forUpdate   bool
+	}{
+		{"sqlite", DialectSQLite, "id = ?", false},
+		{"postgres", DialectPostgres, "id = $8", true},
+		{"mysql", DialectMySQL, "id = ?", true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			db, f := openFakeSQL(t)
+			s, err := NewSQLStore(db, SQLOptions{Dialect: tt.dialect, Key: []byte("secret"), PurgeInterval: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer s.Close()
+
+			if err := s.Set("id", "AbC12"); err != nil {
+				t.Fatal(err)
+			}
+			if hash := s.Get("id", false); hash == "" || strings.Contains(strings.ToLower(hash), "abc12") {
+				t.Errorf("Get() = %q, want the hash of the answer", hash)
+			}
+			if s.Verify("id", "abc13", false) {
+				t.Error("wrong answer verified")
+			}
+			if !s.Verify("id", " abc12 ", true) {
+				t.Error("right answer failed")
+			}
+			if err := s.CheckContext(context.Background(), "id", "abc12", true); err != ErrAlreadyUsed {
+				t.Errorf("CheckContext() of a used captcha = %v", err)
+			}
+
+			var update string
+			for _, q := range f.sent() {
+				if strings.HasPrefix(q, "SELECT answer_hash") && strings.HasSuffix(q, " FOR UPDATE") != tt.forUpdate {
+					t.Errorf("row read with %q", q)
+				}
+				if strings.HasPrefix(q, "UPDATE") {
+					update = q
+				}
+			}
+			if !strings.Contains(update, tt.placeholder) {
+				t.Errorf("update = %q, want %q", update, tt.placeholder)
+			}
+
+			// The migrations are applied once.
+			s2, err := NewSQLStore(db, SQLOptions{Dialect: tt.dialect, Key: []byte("secret"), PurgeInterval: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			s2.Close()
+			if f.version != len(sqlMigrations) {
+				t.Errorf("schema version = %d", f.version)
+			}
+		})
+	}
+}
+
+func TestSQLStore_Check(t *testing.T) {
+	db, _ := openFakeSQL(t)
+	s, err := NewSQLStore(db, SQLOptions{Key: []byte("secret"), PurgeInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	if err := s.CheckContext(ctx, "missing", "1234", true); err != ErrNotFound {
+		t.Errorf("CheckContext() of a missing captcha = %v", err)
+	}
+	s.SetRecordContext(ctx, "limited", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "limited", "", true); err != ErrMismatch {
+		t.Errorf("CheckContext() of an empty answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", true); err != ErrMismatch {
+		t.Errorf("first wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "4321", false); err != ErrTooManyAttempts {
+		t.Errorf("second wrong answer = %v", err)
+	}
+	if err := s.CheckContext(ctx, "limited", "1234", true); err != ErrTooManyAttempts {
+		t.Errorf("right answer after too many attempts = %v", err)
+	}
+
+	s.SetRecordContext(ctx, "bound", Record{Answer: "1234", Binding: "sess"})
+	if err := s.CheckContext(ctx, "bound", "1234", false); err != ErrBindingMismatch {
+		t.Errorf("CheckContext() without binding = %v", err)
+	}
+	sess := ContextWithBinding(ctx, "sess")
+	if err := s.ReplaceRecordContext(ctx, "bound", Record{Answer: "5678"}); err != ErrBindingMismatch {
+		t.Errorf("ReplaceRecordContext() without binding = %v", err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "5678", Binding: "sess"}); err != nil {
+		t.Errorf("ReplaceRecordContext() with binding = %v", err)
+	}
+	if ok, err := s.VerifyContext(sess, "bound", "5678", true); !ok || err != nil {
+		t.Errorf("VerifyContext() of the new answer = %v, %v", ok, err)
+	}
+	if err := s.ReplaceRecordContext(sess, "bound", Record{Answer: "9999", Binding: "sess"}); err != ErrAlreadyUsed {
+		t.Errorf("ReplaceRecordContext() of a used captcha = %v", err)
+	}
+	if ok, _ := s.VerifyContext(sess, "bound", "9999", true); ok {
+		t.Error("ReplaceRecordContext() revived a used captcha")
+	}
+
+	var driver string
+	s.SetRecordContext(ctx, "labelled", Record{Answer: "1234", Driver: "DriverMath"})
+	if err := s.CheckContext(withRecordDriver(ctx, &driver), "labelled", "4321", false); err != ErrMismatch || driver != "DriverMath" {
+		t.Errorf("CheckContext() = %v, driver %q", err, driver)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		matches int
+	)
+	s.Set("concurrent", "1234")
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.Verify("concurrent", "1234", true) {
+				mu.Lock()
+				matches++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if matches != 1 {
+		t.Errorf("%d concurrent verifications matched, want 1", matches)
+	}
+}
+
+func TestSQLStore_purge(t *testing.T) {
+	db, f := openFakeSQL(t)
+	s, err := NewSQLStore(db, SQLOptions{Key: []byte("secret"), Expiration: time.Millisecond, PurgeInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	s.Set("id", "1234")
+	time.Sleep(5 * time.Millisecond)
+	if err := s.CheckContext(context.Background(), "id", "1234", true); err != ErrExpired {
+		t.Errorf("CheckContext() of an expired captcha = %v", err)
+	}
+	if n, err := s.Purge(context.Background()); n != 1 || err != nil {
+		t.Errorf("Purge() = %d, %v, want 1", n, err)
+	}
+
+	s3, err := NewSQLStore(db, SQLOptions{Key: []byte("secret"), Expiration: 50 * time.Millisecond, PurgeInterval: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expired []string
+	s3.OnExpire(func(e ExpireEvent) { expired = append(expired, e.Driver) })
+	s3.SetRecordContext(context.Background(), "unused", Record{Answer: "1234", Driver: "DriverDigit"})
+	s3.SetRecordContext(context.Background(), "used", Record{Answer: "1234", Driver: "DriverMath"})
+	s3.Verify("used", "1234", true)
+	time.Sleep(100 * time.Millisecond)
+	if n, err := s3.Purge(context.Background()); n != 2 || err != nil {
+		t.Errorf("Purge() = %d, %v, want 2", n, err)
+	}
+	if len(expired) != 1 || expired[0] != "DriverDigit" {
+		t.Errorf("expired = %q", expired)
+	}
+	s3.Close()
+
+	s2, err := NewSQLStore(db, SQLOptions{Key: []byte("secret"), Expiration: time.Millisecond, PurgeInterval: 5 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2.Set("id", "1234")
+	deadline := time.Now().Add(time.Second)
+	for {
+		f.lock.Lock()
+		n := len(f.rows)
+		f.lock.Unlock()
+		if n == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired captcha not purged")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s2.Close()
+	s2.Close()
+}
+
+func TestNewSQLStore_errors(t *testing.T) {
+	db, _ := openFakeSQL(t)
+	if _, err := NewSQLStore(db, SQLOptions{}); err == nil {
+		t.Error("NewSQLStore() without a key succeeded")
+	}
+	if _, err := NewSQLStore(db, SQLOptions{Key: []byte("secret"), Table: "captchas; DROP TABLE users"}); err == nil {
+		t.Error("NewSQLStore() with an invalid table name succeeded")
+	}
+}
+
+func TestSQLDialect_rebind(t *testing.T) {
+	q := "UPDATE t SET a = ?, b = ? WHERE id = ?"
+	if got := DialectPostgres.rebind(q); got != "UPDATE t SET a = $1, b = $2 WHERE id = $3" {
+		t.Errorf("rebind() = %q", got)
+	}
+	if got := DialectMySQL.rebind(q); got != q {
+		t.Errorf("rebind() = %q", got)
+	}
+}
@@ -1,15 +1,28 @@
 package base64Captcha
 
 import (
-	"strings"
+	"context"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// StoreSyncMap use sync.Map as store
+// StoreSyncMap is a Store keeping the captchas in a sync.Map. Expired
+// captchas are never returned, and are deleted by a janitor sweeping the
+// map every liveTime. The janitor starts with the first captcha stored,
+// Close stops it.
 type StoreSyncMap struct {
 	liveTime time.Duration
 	m        *sync.Map
+	policy   AnswerPolicy
+
+	started atomic.Bool
+	mu      sync.Mutex
+	stop    chan struct{}
+	closed  bool
+	wg      sync.WaitGroup
+
+	expireHook
 }
 
 // NewStoreSyncMap new a instance
@@ -21,6 +34,13 @@ func NewStoreSyncMap(liveTime time.Duration) *StoreSyncMap {
 type smv struct {
 	t     time.Time
 	Value string
+	// used marks a consumed captcha, it is kept until it expires.
+	used bool
+	// maxAttempts and failures implement the attempt limit of Record.
+	maxAttempts int
+	failures    int
+	binding     string
+	driver      string
 }
 
 // newSmv create a instance
@@ -28,38 +48,263 @@ func newSmv(v string) *smv {
 	return &smv{t: time.Now(), Value: v}
 }
 
+// newRecordSmv creates the value of a record.
+func newRecordSmv(rec Record) *smv {
+	sv := newSmv(rec.Answer)
+	sv.maxAttempts = rec.MaxAttempts
+	sv.binding = rec.Binding
+	sv.driver = rec.Driver
+	return sv
+}
+
+// consumed returns the tombstone replacing a used value.
+func (sv *smv) consumed() *smv {
+	return &smv{t: sv.t, used: true, driver: sv.driver}
+}
+
+// failed returns a copy of the value with one more wrong answer.
+func (sv *smv) failed() *smv {
+	next := *sv
+	next.failures++
+	return &next
+}
+
+// exhausted reports whether the value used up its attempts.
+func (sv *smv) exhausted() bool {
+	return sv.maxAttempts > 0 && sv.failures >= sv.maxAttempts
+}
+
+// expired reports whether the value outlived the store expiration.
+func (s *StoreSyncMap) expired(sv *smv, now time.Time) bool {
+	return sv.t.Add(s.liveTime).Before(now)
+}
+
 // rmExpire remove expired items
-func (s StoreSyncMap) rmExpire() {
-	expireTime := time.Now().Add(-s.liveTime)
+func (s *StoreSyncMap) rmExpire() {
+	now := time.Now()
+	fn := s.expireFunc()
 	s.m.Range(func(key, value interface{}) bool {
-		if sv, ok := value.(*smv); ok && sv.t.Before(expireTime) {
-			s.m.Delete(key)
+		if sv, ok := value.(*smv); ok && s.expired(sv, now) {
+			if s.m.CompareAndDelete(key, value) && fn != nil && !sv.used {
+				fn(ExpireEvent{Driver: sv.driver})
+			}
 		}
 		return true
 	})
 }
 
-// Get get a string value
-func (s StoreSyncMap) Set(id string, value string) {
-	s.rmExpire()
+// startJanitor starts the janitor unless it runs already or the store is
+// closed.
+func (s *StoreSyncMap) startJanitor() {
+	if s.started.Load() {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.started.Load() || s.closed || s.liveTime <= 0 {
+		return
+	}
+	s.stop = make(chan struct{})
+	s.wg.Add(1)
+	go s.janitor(s.stop)
+	s.started.Store(true)
+}
+
+// janitor deletes the expired captchas every liveTime until stop is
+// closed.
+func (s *StoreSyncMap) janitor(stop chan struct{}) {
+	defer s.wg.Done()
+	ticker := time.NewTicker(s.liveTime)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.rmExpire()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Close stops the janitor. The store keeps working, but expired captchas
+// are no longer deleted.
+func (s *StoreSyncMap) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		if s.stop != nil {
+			close(s.stop)
+		}
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+	return nil
+}
+
+// Set sets the digits for the captcha id.
+func (s *StoreSyncMap) Set(id string, value string) error {
+	s.startJanitor()
 	s.m.Store(id, newSmv(value))
+	return nil
+}
+
+// Get returns stored digits for the captcha id. Clear indicates whether the
+// captcha must be consumed, like the memory store it is then kept as used
+// until it expires.
+func (s *StoreSyncMap) Get(id string, clear bool) string {
+	for {
+		v, ok := s.m.Load(id)
+		if !ok {
+			return ""
+		}
+		sv, ok := v.(*smv)
+		if !ok || sv.used || sv.exhausted() || s.expired(sv, time.Now()) {
+			return ""
+		}
+		if clear && !s.m.CompareAndSwap(id, sv, sv.consumed()) {
+			// Another verification changed the value first, look again.
+			continue
+		}
+		return sv.Value
+	}
+}
+
+// Verify check a string value
+func (s *StoreSyncMap) Verify(id, answer string, clear bool) bool {
+	return s.check(context.Background(), id, answer, clear) == nil
+}
+
+// SetAnswerPolicy implements AnswerPolicyStore.
+func (s *StoreSyncMap) SetAnswerPolicy(p AnswerPolicy) {
+	s.policy = p
+}
+
+// Expiration implements ExpiringStore.
+func (s *StoreSyncMap) Expiration() time.Duration {
+	return s.liveTime
 }
 
-// Set a string value
-func (s StoreSyncMap) Get(id string, clear bool) string {
+// load returns the value of captcha id, if any, and why it cannot be
+// verified any more.
+func (s *StoreSyncMap) load(id string) (*smv, error) {
 	v, ok := s.m.Load(id)
 	if !ok {
-		return ""
+		return nil, ErrNotFound
 	}
-	s.m.Delete(id)
-	if sv, ok := v.(*smv); ok {
-		return sv.Value
+	sv, ok := v.(*smv)
+	switch {
+	case !ok:
+		return nil, ErrNotFound
+	case sv.used:
+		return sv, ErrAlreadyUsed
+	case sv.exhausted():
+		return sv, ErrTooManyAttempts
+	case s.expired(sv, time.Now()):
+		return sv, ErrExpired
 	}
-	return ""
+	return sv, nil
 }
 
-// Verify check a string value
-func (s StoreSyncMap) Verify(id, answer string, clear bool) bool {
-	vv := s.Get(id, clear)
-    return strings.EqualFold(vv, answer)
+// check verifies the answer under the answer policy and binding of ctx, and
+// reports why it failed.
+func (s *StoreSyncMap) check(ctx context.Context, id, answer string, clear bool) error {
+	policy := answerPolicyFrom(ctx, s.policy)
+	binding := bindingFrom(ctx)
+	for {
+		sv, err := s.load(id)
+		if sv != nil {
+			reportRecordDriver(ctx, sv.driver)
+		}
+		if err != nil {
+			return err
+		}
+		if answer == "" {
+			return ErrMismatch
+		}
+		var failure error
+		switch {
+		case !bindingsEqual(sv.binding, binding):
+			failure = ErrBindingMismatch
+		case !policy.Match(sv.Value, answer):
+			failure = ErrMismatch
+		}
+		var next *smv
+		switch {
+		case failure != nil && sv.maxAttempts > 0:
+			next = sv.failed()
+		case clear:
+			next = sv.consumed()
+		}
+		if next != nil && !s.m.CompareAndSwap(id, sv, next) {
+			// Another verification changed the value first, look again.
+			continue
+		}
+		if failure != nil && next != nil && next.exhausted() {
+			return ErrTooManyAttempts
+		}
+		return failure
+	}
+}
+
+// SetContext implements StoreContext.
+func (s *StoreSyncMap) SetContext(ctx context.Context, id string, value string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.Set(id, value)
+}
+
+// SetRecordContext implements StoreContext.
+func (s *StoreSyncMap) SetRecordContext(ctx context.Context, id string, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.startJanitor()
+	s.m.Store(id, newRecordSmv(rec))
+	return nil
+}
+
+// GetContext implements StoreContext.
+func (s *StoreSyncMap) GetContext(ctx context.Context, id string, clear bool) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	return s.Get(id, clear), nil
+}
+
+// VerifyContext implements StoreContext.
+func (s *StoreSyncMap) VerifyContext(ctx context.Context, id, answer string, clear bool) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+	return s.check(ctx, id, answer, clear) == nil, nil
+}
+
+// CheckContext implements StoreContext.
+func (s *StoreSyncMap) CheckContext(ctx context.Context, id, answer string, clear bool) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.check(ctx, id, answer, clear)
+}
+
+// ReplaceRecordContext implements ReplaceStore.
+func (s *StoreSyncMap) ReplaceRecordContext(ctx context.Context, id string, rec Record) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	binding := bindingFrom(ctx)
+	for {
+		sv, err := s.load(id)
+		if err != nil {
+			return err
+		}
+		if !bindingsEqual(sv.binding, binding) {
+			return ErrBindingMismatch
+		}
+		if s.m.CompareAndSwap(id, sv, newRecordSmv(rec)) {
+			return nil
+		}
+		// Another verification changed the value first, look again.
+	}
 }
@@ -1,6 +1,7 @@
 package base64Captcha
 
 import (
+	"context"
 	"reflect"
 	"sync"
 	"testing"
@@ -19,7 +20,7 @@ func TestNewStoreSyncMap(t *testing.T) {
 		args args
 		want *StoreSyncMap
 	}{
-		{"new", args{liveTime}, tstore},
+		{"new", args{liveTime}, NewStoreSyncMap(liveTime)},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -159,3 +160,136 @@ func TestStoreSyncMap_rmExpire(t *testing.T) {
 		})
 	}
 }
+
+func TestStoreSyncMap_OnExpire(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreSyncMap(50 * time.Millisecond)
+	var (
+		mu      sync.Mutex
+		expired []string
+	)
+	s.OnExpire(func(e ExpireEvent) {
+		mu.Lock()
+		expired = append(expired, e.Driver)
+		mu.Unlock()
+	})
+	s.SetRecordContext(ctx, "unused", Record{Answer: "1234", Driver: "DriverDigit"})
+	s.SetRecordContext(ctx, "used", Record{Answer: "1234", Driver: "DriverMath"})
+	s.Verify("used", "1234", true)
+	time.Sleep(100 * time.Millisecond)
+	s.Close()
+	s.rmExpire()
+	mu.Lock()
+	defer mu.Unlock()
+	if len(expired) != 1 || expired[0] != "DriverDigit" {
+		t.Errorf("expired = %q", expired)
+	}
+}
+
+func TestStoreSyncMap_CheckContext(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreSyncMap(time.Hour)
+	s.Set("id", "1234")
+	if err := s.CheckContext(ctx, "id", "4321", false); err != ErrMismatch {
+		t.Errorf("wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != nil {
+		t.Errorf("right answer = %v, want nil", err)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrAlreadyUsed {
+		t.Errorf("replay = %v, want %v", err, ErrAlreadyUsed)
+	}
+	if err := s.CheckContext(ctx, "missing", "", true); err != ErrNotFound {
+		t.Errorf("missing = %v, want %v", err, ErrNotFound)
+	}
+	s.m.Store("old", &smv{t: time.Now().Add(-2 * time.Hour), Value: "1234"})
+	if err := s.CheckContext(ctx, "old", "1234", true); err != ErrExpired {
+		t.Errorf("expired = %v, want %v", err, ErrExpired)
+	}
+}
+
+func TestStoreSyncMap_MaxAttempts(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreSyncMap(time.Hour)
+	_ = s.SetRecordContext(ctx, "id", Record{Answer: "1234", MaxAttempts: 2})
+	if err := s.CheckContext(ctx, "id", "1111", true); err != ErrMismatch {
+		t.Errorf("1st wrong answer = %v, want %v", err, ErrMismatch)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", false); err != nil {
+		t.Errorf("fixed typo = %v, want nil", err)
+	}
+	if err := s.CheckContext(ctx, "id", "2222", true); err != ErrTooManyAttempts {
+		t.Errorf("2nd wrong answer = %v, want %v", err, ErrTooManyAttempts)
+	}
+	if err := s.CheckContext(ctx, "id", "1234", true); err != ErrTooManyAttempts {
+		t.Errorf("right answer after limit = %v, want %v", err, ErrTooManyAttempts)
+	}
+}
+
+func TestStoreSyncMap_ConcurrentCheck(t *testing.T) {
+	ctx := context.Background()
+	s := NewStoreSyncMap(time.Hour)
+	s.Set("id", "1234")
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	matches := 0
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if s.CheckContext(ctx, "id", "1234", true) == nil {
+				mu.Lock()
+				matches++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+	if matches != 1 {
+		t.Errorf("%d verifications succeeded, want 1", matches)
+	}
+}
+
+func TestStoreSyncMap_Store(t *testing.T) {
+	var s Store = NewStoreSyncMap(time.Hour)
+	if err := s.Set("id", "1234"); err != nil {
+		t.Fatal(err)
+	}
+	if v := s.Get("id", false); v != "1234" {
+		t.Errorf("Get() = %q", v)
+	}
+	if v := s.Get("id", false); v != "1234" {
+		t.Errorf("Get() without clear consumed the captcha, then %q", v)
+	}
+	if v := s.Get("id", true); v != "1234" {
+		t.Errorf("Get() with clear = %q", v)
+	}
+	if v := s.Get("id", true); v != "" {
+		t.Errorf("Get() after clear = %q", v)
+	}
+}
+
+func TestStoreSyncMap_Janitor(t *testing.T) {
+	s := NewStoreSyncMap(10 * time.Millisecond)
+	s.Set("id", "1234")
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, ok := s.m.Load("id"); !ok {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expired captcha not deleted")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	s.Close()
+	s.Close()
+	s.Set("id", "1234")
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := s.m.Load("id"); !ok {
+		t.Error("expired captcha deleted after Close")
+	}
+	if v := s.Get("id", false); v != "" {
+		t.Errorf("Get() of an expired captcha = %q", v)
+	}
+}
@@ -1,13 +1,99 @@
 package base64Captcha
 
 import (
+	"bytes"
+	"context"
 	"crypto/rand"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
 	"io"
+	"net"
 	"os"
 	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
 )
 
+// bufferPool recycles the buffers of encoded items.
+var bufferPool = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// pngBufferPool recycles the buffers of the PNG encoder.
+type pngBufferPool struct {
+	pool sync.Pool
+}
+
+func (p *pngBufferPool) Get() *png.EncoderBuffer {
+	b, _ := p.pool.Get().(*png.EncoderBuffer)
+	return b
+}
+
+func (p *pngBufferPool) Put(b *png.EncoderBuffer) {
+	p.pool.Put(b)
+}
+
+// pngEncoder encodes the images of items.
+var pngEncoder = &png.Encoder{BufferPool: new(pngBufferPool)}
+
+// countingWriter counts the bytes written to w.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writePNG encodes m to w as PNG.
+func writePNG(w io.Writer, m image.Image) (int64, error) {
+	cw := &countingWriter{w: w}
+	err := pngEncoder.Encode(cw, m)
+	return cw.n, err
+}
+
+// encodeBinary returns what item writes.
+func encodeBinary(item io.WriterTo) ([]byte, error) {
+	var buf bytes.Buffer
+	if _, err := item.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeDataURI writes item to w as a base64 data URI of the MIME type,
+// encoding it as it is written.
+func writeDataURI(w io.Writer, mimeType string, item io.WriterTo) (int64, error) {
+	cw := &countingWriter{w: w}
+	if _, err := io.WriteString(cw, "data:"+mimeType+";base64,"); err != nil {
+		return cw.n, err
+	}
+	enc := base64.NewEncoder(base64.StdEncoding, cw)
+	if _, err := item.WriteTo(enc); err != nil {
+		return cw.n, err
+	}
+	err := enc.Close()
+	return cw.n, err
+}
+
+// encodeDataURI returns item as a base64 data URI of the MIME type.
+func encodeDataURI(mimeType string, item io.WriterTo) (string, error) {
+	buf := bufferPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		bufferPool.Put(buf)
+	}()
+	if _, err := writeDataURI(buf, mimeType, item); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
 // parseDigitsToString parse randomDigits to normal string
 func parseDigitsToString(bytes []byte) string {
 	stringB := make([]byte, len(bytes))
@@ -16,6 +102,41 @@ func parseDigitsToString(bytes []byte) string {
 	}
 	return string(stringB)
 }
+
+// parseDigits parses the digits of content, which must only hold digits.
+func parseDigits(content string) ([]byte, error) {
+	if content == "" {
+		return nil, fmt.Errorf("%w: there are no digits to draw", ErrInvalidConfig)
+	}
+	digits := make([]byte, len(content))
+	for i := 0; i < len(content); i++ {
+		if content[i] < '0' || content[i] > '9' {
+			return nil, fmt.Errorf("%w: content %q is not a number", ErrInvalidConfig, content)
+		}
+		digits[i] = content[i] - '0'
+	}
+	return digits, nil
+}
+
+// minImageSize is the smallest width and height of captcha images, in pixels.
+const minImageSize = 10
+
+// validateImageSize checks the size of a captcha image.
+func validateImageSize(width, height int) error {
+	if width < minImageSize || height < minImageSize {
+		return fmt.Errorf("%w: image of %dx%d pixels is smaller than %dx%d", ErrInvalidConfig, width, height, minImageSize, minImageSize)
+	}
+	return nil
+}
+
+// validateLength checks the length of a captcha answer.
+func validateLength(length int) error {
+	if length < 1 {
+		return fmt.Errorf("%w: length %d must be at least 1", ErrInvalidConfig, length)
+	}
+	return nil
+}
+
 func stringToFakeByte(content string) []byte {
 	digits := make([]byte, len(content))
 	for idx, cc := range content {
@@ -92,3 +213,38 @@ func pathExists(path string) bool {
 	}
 	return false
 }
+
+// connDo runs fn, which talks over c, within the deadline of ctx. If ctx is
+// done first the deadline of c is moved to the past, so that fn returns and
+// c is broken.
+func connDo(ctx context.Context, c net.Conn, fn func() error) error {
+	deadline, _ := ctx.Deadline()
+	if err := c.SetDeadline(deadline); err != nil {
+		return err
+	}
+	stop := context.AfterFunc(ctx, func() {
+		c.SetDeadline(time.Unix(1, 0))
+	})
+	defer stop()
+	return fn()
+}
+
+// closeConn closes a connection after a failed exchange, and returns the
+// error of ctx if it is done, or err. The connection may time out just
+// before ctx, so a timeout under a deadline counts as the deadline.
+func closeConn(ctx context.Context, c io.Closer, err error) error {
+	c.Close()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if _, ok := ctx.Deadline(); ok && errors.Is(err, os.ErrDeadlineExceeded) {
+		return context.DeadlineExceeded
+	}
+	return err
+}
+
+// isConnReset reports whether err tells that the server closed the
+// connection.
+func isConnReset(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE)
+}
@@ -1,10 +1,17 @@
 package base64Captcha
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net"
 	"os"
 	"path/filepath"
 	"reflect"
 	"testing"
+	"time"
 )
 
 func Test_parseDigitsToString(t *testing.T) {
@@ -78,3 +85,57 @@ func Test_pathExists(t *testing.T) {
 		t.Error("failed")
 	}
 }
+
+func Test_parseDigits(t *testing.T) {
+	got, err := parseDigits("0459")
+	if err != nil || !reflect.DeepEqual(got, []byte{0, 4, 5, 9}) {
+		t.Errorf("parseDigits() = %v, %v", got, err)
+	}
+	for _, s := range []string{"", "12a", "١٢"} {
+		if _, err := parseDigits(s); !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("parseDigits(%q) error = %v, want ErrInvalidConfig", s, err)
+		}
+	}
+}
+
+// failingWriter fails every write.
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) { return 0, io.ErrShortWrite }
+
+func Test_writeDataURI(t *testing.T) {
+	body := bytes.Repeat([]byte("captcha"), 100)
+	want := "data:image/png;base64," + base64.StdEncoding.EncodeToString(body)
+
+	var buf bytes.Buffer
+	n, err := writeDataURI(&buf, MimeTypeImage, bytes.NewReader(body))
+	if err != nil || buf.String() != want || n != int64(len(want)) {
+		t.Errorf("writeDataURI() = %d, %v, want %d bytes", n, err, len(want))
+	}
+	if got, err := encodeDataURI(MimeTypeImage, bytes.NewReader(body)); err != nil || got != want {
+		t.Errorf("encodeDataURI() = %.40s..., %v", got, err)
+	}
+	if _, err := writeDataURI(failingWriter{}, MimeTypeImage, bytes.NewReader(body)); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("writeDataURI() error = %v, want io.ErrShortWrite", err)
+	}
+}
+
+func Test_connDo(t *testing.T) {
+	c, peer := net.Pipe()
+	defer peer.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	err := connDo(ctx, c, func() error {
+		_, err := c.Read(make([]byte, 1))
+		return err
+	})
+	if err = closeConn(ctx, c, err); err != context.DeadlineExceeded {
+		t.Errorf("closeConn() = %v, want context.DeadlineExceeded", err)
+	}
+	if _, err := c.Write([]byte{0}); err == nil {
+		t.Error("connection not closed")
+	}
+	if !isConnReset(io.EOF) || isConnReset(io.ErrUnexpectedEOF) {
+		t.Error("isConnReset() misses io.EOF or matches io.ErrUnexpectedEOF")
+	}
+}